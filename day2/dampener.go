@@ -0,0 +1,209 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// DampenResult describes the outcome of running a report through the Problem Dampener.
+type DampenResult struct {
+	// Safe is true if the report can be made safe within the tolerance.
+	Safe bool
+	// Removals is the minimum number of levels that must be removed. Only meaningful when Safe is true.
+	Removals int
+	// Removed holds the indices of the levels to remove, in ascending order.
+	Removed []int
+}
+
+// Dampen determines whether a report can be made safe by removing at most tolerance levels.
+// It keeps the longest run of levels that follows a single pattern, where two kept levels can
+// be at most tolerance+1 positions apart, so each search is O(n*tolerance).
+// When the rules have a max span, a search is run for every window of levels the span allows.
+// Custom predicates can reject any run, not only the longest one, so with predicates every set of
+// removals is tried instead, from the smallest up.
+func Dampen(levels []int, tolerance int, rules SafetyRules) DampenResult {
+	if len(rules.Predicates) > 0 {
+		return dampenByEnumeration(levels, tolerance, rules)
+	}
+
+	best := DampenResult{}
+	bestRemovals := math.MaxInt
+
+	// Without a max span, a single window covers every level
+	windows := [][2]int{{math.MinInt, math.MaxInt}}
+	if rules.MaxSpan > 0 {
+		windows = windows[:0]
+		for _, lowest := range levels {
+			windows = append(windows, [2]int{lowest, lowest + rules.MaxSpan})
+		}
+	}
+
+	for _, pattern := range rules.patterns() {
+		for _, window := range windows {
+			removals, kept := dampenPattern(levels, tolerance, rules, pattern, window)
+			if kept == nil || removals >= bestRemovals {
+				continue
+			}
+
+			bestRemovals = removals
+			best = DampenResult{
+				Safe:     true,
+				Removals: removals,
+				Removed:  removedIndices(len(levels), kept),
+			}
+		}
+	}
+
+	return best
+}
+
+// dampenPattern returns the minimum number of removals needed for the levels to follow the given pattern
+// while only keeping levels inside the window, along with the indices of the levels that are kept.
+// If it is not possible within the tolerance, kept is nil.
+func dampenPattern(levels []int, tolerance int, rules SafetyRules, pattern string, window [2]int) (int, []int) {
+	n := len(levels)
+	impossible := math.MaxInt
+
+	// cost[i] is the fewest removals among levels[:i+1] when level i is kept and follows at least one other kept level.
+	// parent[i] is the kept level right before level i, or -1 if level i starts the kept run.
+	cost := make([]int, n)
+	parent := make([]int, n)
+
+	// startCost returns the fewest removals among levels[:j+1] when level j is kept, and the level kept before it.
+	startCost := func(j int) (int, int) {
+		if cost[j] < j {
+			return cost[j], parent[j]
+		}
+		return j, -1
+	}
+
+	inWindow := func(i int) bool {
+		return levels[i] >= window[0] && levels[i] <= window[1]
+	}
+
+	for i := 0; i < n; i++ {
+		cost[i] = impossible
+		parent[i] = -1
+
+		if !inWindow(i) {
+			continue
+		}
+
+		for j := max(0, i-tolerance-1); j < i; j++ {
+			if !inWindow(j) || !rules.stepAllowed(levels[j], levels[i], pattern) {
+				continue
+			}
+
+			prevCost, _ := startCost(j)
+			if prevCost > tolerance {
+				continue
+			}
+
+			if c := prevCost + i - j - 1; c < cost[i] {
+				cost[i] = c
+				parent[i] = j
+			}
+		}
+	}
+
+	bestEnd := -1
+	bestRemovals := impossible
+	for i := 0; i < n; i++ {
+		if cost[i] == impossible {
+			continue
+		}
+
+		if removals := cost[i] + n - 1 - i; removals <= tolerance && removals < bestRemovals {
+			bestRemovals = removals
+			bestEnd = i
+		}
+	}
+
+	if bestEnd == -1 {
+		return impossible, nil
+	}
+
+	// Walk back through the kept levels
+	kept := []int{bestEnd}
+	for j := parent[bestEnd]; j != -1; _, j = startCost(j) {
+		kept = append([]int{j}, kept...)
+	}
+
+	return bestRemovals, kept
+}
+
+// dampenByEnumeration tries every set of at most tolerance levels to remove, fewest removals first and in
+// lexicographic order of indices, and returns the first set that leaves a safe report. It takes
+// O(C(n, tolerance)*n) time, so it is only used when custom predicates rule out the faster search.
+func dampenByEnumeration(levels []int, tolerance int, rules SafetyRules) DampenResult {
+	n := len(levels)
+	kept := make([]int, 0, n)
+
+	for removals := 0; removals <= min(tolerance, n); removals++ {
+		// removed holds the indices of the levels to remove, in ascending order
+		removed := make([]int, removals)
+		for i := range removed {
+			removed[i] = i
+		}
+
+		for {
+			kept = kept[:0]
+			r := 0
+			for i, level := range levels {
+				if r < len(removed) && removed[r] == i {
+					r++
+					continue
+				}
+				kept = append(kept, level)
+			}
+
+			if SafetyCheck(kept, rules).Safe {
+				return DampenResult{Safe: true, Removals: removals, Removed: removed}
+			}
+
+			// Move to the next set of indices, advancing the last one that can still move right
+			i := removals - 1
+			for i >= 0 && removed[i] == n-removals+i {
+				i--
+			}
+			if i < 0 {
+				break
+			}
+			removed[i]++
+			for j := i + 1; j < removals; j++ {
+				removed[j] = removed[j-1] + 1
+			}
+		}
+	}
+
+	return DampenResult{}
+}
+
+// removedIndices returns the indices in [0, n) that are not in kept. Both are in ascending order.
+func removedIndices(n int, kept []int) []int {
+	removed := []int{}
+	k := 0
+	for i := 0; i < n; i++ {
+		if k < len(kept) && kept[k] == i {
+			k++
+			continue
+		}
+		removed = append(removed, i)
+	}
+
+	return removed
+}
+
+// parseLevels converts the levels of a report from strings to ints.
+func parseLevels(nums []string) ([]int, error) {
+	levels := make([]int, len(nums))
+	for index, num := range nums {
+		level, err := strconv.Atoi(num)
+		if err != nil {
+			return nil, err
+		}
+		levels[index] = level
+	}
+
+	return levels, nil
+}
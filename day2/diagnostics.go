@@ -0,0 +1,93 @@
+package main
+
+import "log/slog"
+
+// Kinds of violations that make a report unsafe.
+const (
+	directionChange = "direction change"
+	stepTooLarge    = "step too large"
+	stepTooSmall    = "step too small"
+	flatStep        = "flat step"
+	spanTooLarge    = "span too large"
+	predicateFailed = "predicate failed"
+	tooFewLevels    = "too few levels"
+)
+
+// violationKinds lists every kind of violation in the order they are summarized.
+var violationKinds = []string{directionChange, stepTooLarge, stepTooSmall, flatStep, spanTooLarge, predicateFailed, tooFewLevels}
+
+// Violation describes one way a report breaks the safety rules.
+type Violation struct {
+	// Index is the index of the level where the violation was found, or -1 if it concerns the whole report.
+	Index int    `json:"index"`
+	Kind  string `json:"kind"`
+	// Values are the offending levels, such as the two levels of a step or the lowest and highest levels of a span.
+	Values []int `json:"values,omitempty"`
+	// Predicate is the name of the custom predicate that failed, if any.
+	Predicate string `json:"predicate,omitempty"`
+}
+
+// Diagnosis is the result of checking a report against the safety rules.
+type Diagnosis struct {
+	Safe       bool        `json:"safe"`
+	Violations []Violation `json:"violations"`
+}
+
+// UnsafeReport is a report that failed the safety check, along with why.
+type UnsafeReport struct {
+	LineNumber int         `json:"lineNumber"`
+	Line       string      `json:"line"`
+	Violations []Violation `json:"violations"`
+}
+
+// Summary aggregates the violations of unsafe reports across a file.
+type Summary struct {
+	counts  map[string]int
+	reports map[string][]UnsafeReport
+}
+
+// NewSummary returns an empty summary.
+func NewSummary() *Summary {
+	return &Summary{
+		counts:  make(map[string]int),
+		reports: make(map[string][]UnsafeReport),
+	}
+}
+
+// Add records the violations of an unsafe report. A report is grouped under every kind of violation it has.
+func (s *Summary) Add(lineNumber int, line string, diagnosis Diagnosis) {
+	report := UnsafeReport{
+		LineNumber: lineNumber,
+		Line:       line,
+		Violations: diagnosis.Violations,
+	}
+
+	grouped := make(map[string]bool)
+	for _, violation := range diagnosis.Violations {
+		s.counts[violation.Kind]++
+
+		if !grouped[violation.Kind] {
+			grouped[violation.Kind] = true
+			s.reports[violation.Kind] = append(s.reports[violation.Kind], report)
+		}
+	}
+}
+
+// Log logs the number of violations of each kind, followed by the unsafe reports grouped by kind.
+func (s *Summary) Log(logger *slog.Logger) {
+	counts := make(map[string]int)
+	for _, kind := range violationKinds {
+		if s.counts[kind] > 0 {
+			counts[kind] = s.counts[kind]
+		}
+	}
+	logger.Info("violation summary is ready!", "violation counts", counts)
+
+	for _, kind := range violationKinds {
+		if len(s.reports[kind]) == 0 {
+			continue
+		}
+
+		logger.Info("unsafe reports", "reason", kind, "report count", len(s.reports[kind]), "reports", s.reports[kind])
+	}
+}
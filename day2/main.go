@@ -1,18 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"log/slog"
-	"math"
 	"os"
-	"strconv"
-	"strings"
-)
-
-const (
-	increasing = "increasing"
-	decreasing = "decreasing"
+	"runtime"
+	"time"
 )
 
 func main() {
@@ -20,11 +13,48 @@ func main() {
 
 	// Get input file name
 	inputFlag := flag.String("input", "input/test_input.txt", "A file containing puzzle inputs.")
+	workersFlag := flag.Int("workers", runtime.NumCPU(), "The number of workers evaluating reports concurrently.")
+	toleranceFlag := flag.Int("tolerance", 1, "The maximum number of levels the Problem Dampener can remove from a report.")
+	rulesFlag := flag.String("rules", "", "A JSON file containing presets of safety rules.")
+	presetFlag := flag.String("preset", defaultPreset, "The preset of safety rules to use.")
+	repairFlag := flag.Bool("repair", false, "Suggest the fewest levels to modify to make each unsafe report safe.")
+	summaryFlag := flag.Bool("summary", false, "Summarize why reports are unsafe, grouping unsafe reports by reason.")
+	dampenedFlag := flag.Bool("dampened", false, "Log every report the Problem Dampener makes safe, with the levels it removes.")
+	overrides := defineRuleFlags()
 	flag.Parse()
 
 	inputFileName := *inputFlag
+	tolerance := *toleranceFlag
+	workers := *workersFlag
 	logger = logger.With(
 		slog.String("inputFileName", inputFileName),
+		slog.Int("tolerance", tolerance),
+	)
+
+	if workers < 1 {
+		logger.Error("there must be at least one worker", "workers", workers)
+		os.Exit(1)
+	}
+
+	if tolerance < 0 {
+		logger.Error("tolerance cannot be negative")
+		os.Exit(1)
+	}
+
+	// Load safety rules
+	rules, err := loadRules(*rulesFlag, *presetFlag)
+	if err != nil {
+		logger.Error("failed to load safety rules", "error", err, "preset", *presetFlag)
+		os.Exit(1)
+	}
+
+	rules = overrides.apply(rules)
+	if err := rules.Validate(); err != nil {
+		logger.Error("invalid safety rules", "error", err, "rules", rules)
+		os.Exit(1)
+	}
+	logger = logger.With(
+		slog.String("preset", *presetFlag),
 	)
 
 	// Open input file
@@ -36,118 +66,165 @@ func main() {
 	defer file.Close()
 
 	var (
-		part1valid = 0
-		part2valid = 0
+		reportCount int
+		part1valid  = 0
+		part2valid  = 0
+		// The summary keeps every unsafe report, so it is only built when asked for
+		summary *Summary
+		// Number of unsafe reports that can be repaired, grouped by the number of levels to modify
+		repairCounts      = make(map[int]int)
+		unrepairableCount int
 	)
 
-	// Read input file line by line
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		nums := strings.Fields(line)
+	if *summaryFlag {
+		summary = NewSummary()
+	}
+
+	evaluate := func(report Report) ReportResult {
+		return evaluateReport(report, tolerance, rules, *repairFlag)
+	}
+
+	// Tally the reports in line order
+	tally := func(result ReportResult) error {
+		reportCount++
 
 		tmpLogger := logger.With(
-			slog.String("line read", line),
-			slog.Int("numbers per line", len(nums)),
-			slog.Any("numbers found", nums),
+			slog.Int("line number", result.LineNumber),
+			slog.String("line read", result.Line),
+			slog.Int("numbers per line", len(result.Nums)),
+			slog.Any("numbers found", result.Nums),
 		)
 
-		// Check if report is safe
-		safe, err := SafetyCheck(nums)
-		if err != nil {
-			tmpLogger.Error("unable to determine if report is safe", "error", err)
-			os.Exit(1)
+		if result.Err != nil {
+			tmpLogger.Error("unable to convert levels from string to int", "error", result.Err)
+			return result.Err
 		}
 
-		if safe {
+		if result.Diagnosis.Safe {
 			part1valid++
-			part2valid++
-		} else {
-			for index := 0; index < len(nums); index++ {
-				copyOfNums := make([]string, len(nums))
-				copy(copyOfNums, nums)
+		} else if summary != nil {
+			summary.Add(result.LineNumber, result.Line, result.Diagnosis)
+		}
 
-				copyOfNums = append(copyOfNums[:index], copyOfNums[index+1:]...)
+		if result.Dampened.Safe {
+			part2valid++
 
-				safe, err := SafetyCheck(copyOfNums)
-				if err != nil {
-					tmpLogger.Error("unable to determine if report is safe", "error", err, "new numbers", copyOfNums)
-					os.Exit(1)
-				}
+			if *dampenedFlag && result.Dampened.Removals > 0 {
+				tmpLogger.Info("report made safe by the problem dampener", "removals", result.Dampened.Removals, "removed indices", result.Dampened.Removed)
+			}
+		}
 
-				if safe {
-					part2valid++
-					break
+		if *repairFlag && !result.Diagnosis.Safe {
+			if result.Repair == nil {
+				unrepairableCount++
+				tmpLogger.Info("report cannot be repaired")
+			} else {
+				repairCounts[result.Repair.Modifications]++
+				tmpLogger.Info("report can be repaired", "modifications", result.Repair.Modifications, "changes", result.Repair.Changes, "repaired", result.Repair.Repaired, "minimal", result.Repair.Minimal)
+				if result.Repair.SpanSearchCapped {
+					tmpLogger.Warn("max span search is too costly for the report, so the repair may not be minimal", "max span", rules.MaxSpan, "max step", rules.MaxStep)
 				}
-
 			}
 		}
+
+		return nil
+	}
+
+	// Evaluate reports concurrently
+	start := time.Now()
+	if err := processReports(file, workers, evaluate, tally); err != nil {
+		logger.Error("failed to process reports", "error", err)
+		os.Exit(1)
 	}
+	elapsed := time.Since(start)
+
+	logger.Info("throughput", "report count", reportCount, "elapsed", elapsed.String(), "reports per second", float64(reportCount)/elapsed.Seconds())
 
 	// Part 1 Solution
 	logger.Info("result #1 is ready!", "valid count", part1valid)
 
 	// Part 2 Solution
 	logger.Info("result #2 is ready!", "valid count", part2valid)
-}
 
-func SafetyCheck(nums []string) (bool, error) {
-	previous := 0
-	pattern := ""
-	safe := false
+	// Repair Suggestions
+	if *repairFlag {
+		logger.Info("repair suggestions are ready!", "repairable count by modifications", repairCounts, "unrepairable count", unrepairableCount)
+	}
 
-	for index, num := range nums {
+	if summary != nil {
+		summary.Log(logger)
+	}
+}
 
-		level, err := strconv.Atoi(num)
-		if err != nil {
-			return false, err
-		}
+// SafetyCheck diagnoses if the report follows the safety rules, listing every violation found.
+func SafetyCheck(levels []int, rules SafetyRules) Diagnosis {
+	var (
+		previous     int
+		lowest       int
+		highest      int
+		extremeIndex int
+		diagnosis    Diagnosis
+	)
+	pattern := rules.Direction
 
+	for index, level := range levels {
 		// If this is the first level, save it and conitnue to the next level
 		if index == 0 {
-			previous = level
+			previous, lowest, highest = level, level, level
 			continue
 		}
 
-		// If this is the second level, determine if the pattern is increasing or decreasing
-		if index == 1 {
+		// If the pattern can go either way, the first step that is not flat determines if it is increasing or decreasing
+		if pattern == either && previous != level {
 			if previous < level {
 				pattern = increasing
-			} else if previous > level {
-				pattern = decreasing
 			} else {
-				// Invalid because it is not increasing or decreasing
-				break
+				pattern = decreasing
 			}
 		}
 
-		// If this is the third level and on, check if it matches the established pattern
-		if index > 1 {
-			if pattern == increasing && previous > level {
-				// Invalid because the pattern is increasing but the current level is decreasing
-				break
-			}
-
-			if pattern == decreasing && previous < level {
-				// Invalid because the pattern is decreasing but the current level is increasing
-				break
-			}
+		// Record every way the step breaks the rules
+		for _, kind := range rules.stepViolations(previous, level, pattern) {
+			diagnosis.Violations = append(diagnosis.Violations, Violation{
+				Index:  index,
+				Kind:   kind,
+				Values: []int{previous, level},
+			})
 		}
 
-		diff := math.Abs(float64(previous - level))
-		if diff < 1 || diff > 3 {
-			// Invalid because the difference is not between 1 and 3
-			break
+		// Remember the level that most recently extended the span
+		if level < lowest || level > highest {
+			extremeIndex = index
 		}
+		lowest = min(lowest, level)
+		highest = max(highest, level)
+		previous = level
+	}
 
-		// If we are at the end, then this report passed all the checks
-		if index == len(nums)-1 {
-			safe = true
-			break
-		}
+	// A report needs at least two levels to be safe
+	if len(levels) < 2 {
+		diagnosis.Violations = append(diagnosis.Violations, Violation{
+			Index:  len(levels) - 1,
+			Kind:   tooFewLevels,
+			Values: levels,
+		})
+	} else if !rules.spanAllowed(lowest, highest) {
+		diagnosis.Violations = append(diagnosis.Violations, Violation{
+			Index:  extremeIndex,
+			Kind:   spanTooLarge,
+			Values: []int{lowest, highest},
+		})
+	}
 
-		previous = level
+	for _, name := range rules.failedPredicates(levels) {
+		diagnosis.Violations = append(diagnosis.Violations, Violation{
+			Index:     -1,
+			Kind:      predicateFailed,
+			Predicate: name,
+		})
 	}
 
-	return safe, nil
+	diagnosis.Safe = len(diagnosis.Violations) == 0
+
+	return diagnosis
 }
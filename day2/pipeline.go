@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Report is one line of the input file.
+type Report struct {
+	LineNumber int
+	Line       string
+}
+
+// ReportResult holds the evaluation of a report for both parts.
+type ReportResult struct {
+	Report
+	// Nums are the fields of the line and Levels are the same fields parsed once into ints.
+	Nums   []string
+	Levels []int
+	// Diagnosis is the part 1 safety check and Dampened is the part 2 Problem Dampener result.
+	Diagnosis Diagnosis
+	Dampened  DampenResult
+	// Repair is the suggested repair of an unsafe report, if repairs were requested and one was found.
+	Repair *Repair
+	// Err is set if the line could not be parsed.
+	Err error
+}
+
+// evaluateReport parses a report once and evaluates it for both parts.
+// If repair is true, it also suggests the smallest repair of an unsafe report.
+func evaluateReport(report Report, tolerance int, rules SafetyRules, repair bool) ReportResult {
+	result := ReportResult{
+		Report: report,
+		Nums:   strings.Fields(report.Line),
+	}
+
+	result.Levels, result.Err = parseLevels(result.Nums)
+	if result.Err != nil {
+		return result
+	}
+
+	result.Diagnosis = SafetyCheck(result.Levels, rules)
+	result.Dampened = Dampen(result.Levels, tolerance, rules)
+
+	if repair && !result.Diagnosis.Safe {
+		if suggestion, ok := SuggestRepair(result.Levels, rules); ok {
+			result.Repair = &suggestion
+		}
+	}
+
+	return result
+}
+
+// processReports streams the reports of r through a pool of workers running evaluate.
+// Results are handed to emit in line order, so the aggregated output does not depend on scheduling.
+// At most workers*64 reports are read ahead of the last one emitted, so a slow report holds back the reader
+// instead of letting the results behind it pile up. Processing stops at the first error returned by emit,
+// which is then returned.
+func processReports(r io.Reader, workers int, evaluate func(Report) ReportResult, emit func(ReportResult) error) error {
+	jobs := make(chan Report, workers*64)
+	results := make(chan ReportResult, workers*64)
+	done := make(chan struct{})
+	defer close(done)
+
+	// window holds a slot for each report read and not yet emitted
+	window := make(chan struct{}, workers*64)
+
+	// Read reports line by line
+	var scanErr error
+	go func() {
+		defer close(jobs)
+
+		scanner := bufio.NewScanner(r)
+		lineNumber := 0
+		for scanner.Scan() {
+			lineNumber++
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			}
+
+			select {
+			case jobs <- Report{LineNumber: lineNumber, Line: scanner.Text()}:
+			case <-done:
+				return
+			}
+		}
+		scanErr = scanner.Err()
+	}()
+
+	// Fan reports out to the workers
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				select {
+				case results <- evaluate(job):
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Hold results that arrive early until every line before them has been emitted
+	pending := make(map[int]ReportResult)
+	next := 1
+	for result := range results {
+		pending[result.LineNumber] = result
+
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-window
+
+			if err := emit(result); err != nil {
+				return err
+			}
+		}
+	}
+
+	return scanErr
+}
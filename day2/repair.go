@@ -0,0 +1,373 @@
+package main
+
+import (
+	"sort"
+)
+
+// Repair describes the smallest change to the readings of a report that makes it safe.
+type Repair struct {
+	// Modifications is the number of levels whose values must change.
+	Modifications int `json:"modifications"`
+	// Changes lists the suggested replacement value for each modified level.
+	Changes []LevelChange `json:"changes"`
+	// Repaired is the report after the changes.
+	Repaired []int `json:"repaired"`
+	// Minimal is true if no repair modifies fewer levels. It is false when the rules have custom predicates,
+	// which can only be checked on the repairs that are tried, or when the span search is capped.
+	Minimal bool `json:"minimal"`
+	// SpanSearchCapped is true when the max span search would have cost more than maxSpanSearchCost, so only
+	// the repairs keeping runs of levels were tried.
+	SpanSearchCapped bool `json:"spanSearchCapped,omitempty"`
+}
+
+// maxSpanSearchCost bounds the number of steps spanRepairs may try for one report, around a third of a second.
+const maxSpanSearchCost = 50_000_000
+
+// LevelChange is a suggested replacement value for one level.
+type LevelChange struct {
+	Index int `json:"index"`
+	From  int `json:"from"`
+	To    int `json:"to"`
+}
+
+// repairCandidate holds the indices of the levels kept unchanged and the pattern the repaired report follows.
+type repairCandidate struct {
+	pattern string
+	kept    []int
+}
+
+// SuggestRepair finds the minimum number of levels whose values must be modified for the report to be safe,
+// along with suggested replacement values. Without a binding max span, levels that are kept unchanged must be
+// reachable from each other in the number of steps between them, which is checked for every pair so the search
+// is O(n^2) per starting level. With a max span, every level of the repair must also fit in a window of the span,
+// so the repair is searched value by value in each window instead. When that search would cost more than
+// maxSpanSearchCost, the repairs of the search without a span are tried instead, keeping those within the span,
+// and the repair is marked capped and not minimal.
+// Every candidate is verified with SafetyCheck. Custom predicates can only be checked that way, so with
+// predicates the repair is the best of the candidates that satisfy them and is not marked minimal.
+// At least one level is always kept. It returns false if the report cannot be repaired,
+// such as when it has fewer than two levels.
+func SuggestRepair(levels []int, rules SafetyRules) (Repair, bool) {
+	n := len(levels)
+	if n < 2 {
+		return Repair{}, false
+	}
+
+	// Steps of at most maxStep can not spread n levels further than (n-1)*maxStep, so a larger span never binds
+	var candidates [][]int
+	capped := false
+	if rules.MaxSpan > 0 && rules.MaxSpan < (n-1)*rules.MaxStep {
+		if rules.spanSearchCost(n) <= maxSpanSearchCost {
+			candidates = rules.spanRepairs(levels)
+		} else {
+			candidates = rules.runRepairs(levels)
+			capped = true
+		}
+	} else {
+		candidates = rules.runRepairs(levels)
+	}
+
+	modifications := func(repaired []int) int {
+		count := 0
+		for index, level := range levels {
+			if repaired[index] != level {
+				count++
+			}
+		}
+		return count
+	}
+
+	// Try the candidates modifying the fewest levels first
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return modifications(candidates[a]) < modifications(candidates[b])
+	})
+
+	for _, repaired := range candidates {
+		if !SafetyCheck(repaired, rules).Safe {
+			continue
+		}
+
+		repair := Repair{
+			Changes:  []LevelChange{},
+			Repaired: repaired,
+			Minimal:  len(rules.Predicates) == 0 && !capped,
+
+			SpanSearchCapped: capped,
+		}
+		for index, level := range levels {
+			if repaired[index] != level {
+				repair.Changes = append(repair.Changes, LevelChange{Index: index, From: level, To: repaired[index]})
+			}
+		}
+		repair.Modifications = len(repair.Changes)
+
+		return repair, true
+	}
+
+	return Repair{}, false
+}
+
+// runRepairs returns, for each pattern and pair of first and last kept levels, the report that keeps the longest
+// run of levels between them unchanged, with the other levels filled in.
+func (r SafetyRules) runRepairs(levels []int) [][]int {
+	n := len(levels)
+
+	var repairs [][]int
+	for _, pattern := range r.patterns() {
+		for first := 0; first < n; first++ {
+			// count[i] is the most levels kept from first to i when level i is kept, 0 if impossible.
+			// parent[i] is the kept level right before level i.
+			count := make([]int, n)
+			parent := make([]int, n)
+			count[first] = 1
+			parent[first] = -1
+
+			for i := first + 1; i < n; i++ {
+				for j := first; j < i; j++ {
+					if count[j] == 0 || count[j]+1 <= count[i] {
+						continue
+					}
+
+					if _, ok := r.segmentSteps(levels[i]-levels[j], i-j, pattern); ok {
+						count[i] = count[j] + 1
+						parent[i] = j
+					}
+				}
+			}
+
+			for last := first; last < n; last++ {
+				if count[last] == 0 {
+					continue
+				}
+
+				kept := make([]int, count[last])
+				for k, j := len(kept)-1, last; j != -1; k, j = k-1, parent[j] {
+					kept[k] = j
+				}
+
+				if repaired, ok := r.fillRepair(levels, repairCandidate{pattern: pattern, kept: kept}); ok {
+					repairs = append(repairs, repaired)
+				}
+			}
+		}
+	}
+
+	return repairs
+}
+
+// spanSearchCost returns the number of steps spanRepairs tries for a report of n levels at most: every value of
+// every level, from every value of the level before it within a step, in each window of each pattern.
+func (r SafetyRules) spanSearchCost(n int) int {
+	width := r.MaxSpan + 1
+	return len(r.patterns()) * n * width * n * width * (2*r.MaxStep + 1)
+}
+
+// spanRepairs returns, for each pattern and window of values the max span allows, the report that keeps the most
+// levels unchanged while every level stays in the window. The lowest level of such a repair is at most span below
+// one of its kept levels, which bounds the windows to try. Each window is searched over every value of every level,
+// so it takes O(n^2*span^2*maxStep) time, which only happens when the span is smaller than the steps could reach.
+func (r SafetyRules) spanRepairs(levels []int) [][]int {
+	n := len(levels)
+	width := r.MaxSpan + 1
+
+	var windows []int
+	tried := make(map[int]bool)
+	for _, level := range levels {
+		for lowest := level - r.MaxSpan; lowest <= level; lowest++ {
+			if !tried[lowest] {
+				tried[lowest] = true
+				windows = append(windows, lowest)
+			}
+		}
+	}
+
+	var repairs [][]int
+	for _, pattern := range r.patterns() {
+		for _, lowest := range windows {
+			// kept[i][v] is the most levels kept among levels[:i+1] when level i is lowest+v, -1 if impossible.
+			// parent[i][v] is the value of level i-1 it follows.
+			kept := make([][]int, n)
+			parent := make([][]int, n)
+			for i := range kept {
+				kept[i] = make([]int, width)
+				parent[i] = make([]int, width)
+
+				for v := range kept[i] {
+					keep := 0
+					if lowest+v == levels[i] {
+						keep = 1
+					}
+
+					if i == 0 {
+						kept[i][v] = keep
+						continue
+					}
+
+					kept[i][v] = -1
+					for u := max(0, v-r.MaxStep); u <= min(width-1, v+r.MaxStep); u++ {
+						if kept[i-1][u] >= 0 && kept[i-1][u]+keep > kept[i][v] && r.stepAllowed(lowest+u, lowest+v, pattern) {
+							kept[i][v] = kept[i-1][u] + keep
+							parent[i][v] = u
+						}
+					}
+				}
+			}
+
+			best := -1
+			for v, count := range kept[n-1] {
+				if count > 0 && (best == -1 || count > kept[n-1][best]) {
+					best = v
+				}
+			}
+			if best == -1 {
+				continue
+			}
+
+			repaired := make([]int, n)
+			for i, v := n-1, best; i >= 0; i, v = i-1, parent[i][v] {
+				repaired[i] = lowest + v
+			}
+			repairs = append(repairs, repaired)
+		}
+	}
+
+	return repairs
+}
+
+// fillRepair returns the report with the kept levels unchanged and every other level replaced so that the steps follow the pattern.
+func (r SafetyRules) fillRepair(levels []int, candidate repairCandidate) ([]int, bool) {
+	n := len(levels)
+	repaired := make([]int, n)
+
+	kept := candidate.kept
+	first, last := kept[0], kept[len(kept)-1]
+	for _, index := range kept {
+		repaired[index] = levels[index]
+	}
+
+	// Fill the levels between each pair of kept levels
+	for k := 1; k < len(kept); k++ {
+		from, to := kept[k-1], kept[k]
+		steps, ok := r.segmentSteps(levels[to]-levels[from], to-from, candidate.pattern)
+		if !ok {
+			return nil, false
+		}
+
+		for i, step := range steps[:len(steps)-1] {
+			repaired[from+i+1] = repaired[from+i] + step
+		}
+	}
+
+	// Extend before the first and after the last kept level with the smallest steps allowed
+	small, ok := r.smallestStep(candidate.pattern)
+	if !ok {
+		return nil, false
+	}
+
+	for i := first - 1; i >= 0; i-- {
+		step := small
+		if candidate.pattern == anyDirection && (first-i)%2 == 0 {
+			step = -small
+		}
+		repaired[i] = repaired[i+1] - step
+	}
+
+	for i := last + 1; i < n; i++ {
+		step := small
+		if candidate.pattern == anyDirection && (i-last)%2 == 0 {
+			step = -small
+		}
+		repaired[i] = repaired[i-1] + step
+	}
+
+	return repaired, true
+}
+
+// smallestStep returns the step with the smallest difference allowed by the pattern.
+func (r SafetyRules) smallestStep(pattern string) (int, bool) {
+	if r.AllowEqual {
+		return 0, true
+	}
+
+	low, high := r.stepBounds()
+	if low > high {
+		return 0, false
+	}
+
+	if pattern == decreasing {
+		return -low, true
+	}
+	return low, true
+}
+
+// stepBounds returns the smallest and largest differences allowed between two adjacent levels that are not equal.
+func (r SafetyRules) stepBounds() (int, int) {
+	return max(r.MinStep, 1), r.MaxStep
+}
+
+// segmentSteps returns steps following the pattern that add up to diff in exactly count steps.
+// A step is either flat, if equal levels are allowed, or rises or falls by a difference within the step bounds.
+func (r SafetyRules) segmentSteps(diff int, count int, pattern string) ([]int, bool) {
+	low, high := r.stepBounds()
+	if low > high {
+		// Only flat steps are possible
+		if r.AllowEqual && diff == 0 {
+			return make([]int, count), true
+		}
+		return nil, false
+	}
+
+	for rises := 0; rises <= count; rises++ {
+		for falls := 0; rises+falls <= count; falls++ {
+			flats := count - rises - falls
+			if flats > 0 && !r.AllowEqual {
+				continue
+			}
+
+			if (pattern == increasing && falls > 0) || (pattern == decreasing && rises > 0) {
+				continue
+			}
+
+			// The rises add up to up and the falls add up to down, with diff = up - down
+			if diff < rises*low-falls*high || diff > rises*high-falls*low {
+				continue
+			}
+
+			down := max(falls*low, rises*low-diff)
+			up := down + diff
+
+			steps := make([]int, 0, count)
+			riseSteps := splitSum(up, rises, low, high)
+			fallSteps := splitSum(down, falls, low, high)
+
+			// Alternate rises and falls to keep the span small
+			for len(riseSteps) > 0 || len(fallSteps) > 0 {
+				if len(riseSteps) > 0 {
+					steps = append(steps, riseSteps[0])
+					riseSteps = riseSteps[1:]
+				}
+				if len(fallSteps) > 0 {
+					steps = append(steps, -fallSteps[0])
+					fallSteps = fallSteps[1:]
+				}
+			}
+			steps = append(steps, make([]int, flats)...)
+
+			return steps, true
+		}
+	}
+
+	return nil, false
+}
+
+// splitSum splits total into count parts that are each between low and high. total must be within reach.
+func splitSum(total int, count int, low int, high int) []int {
+	parts := make([]int, count)
+	extra := total - count*low
+	for i := range parts {
+		add := min(extra, high-low)
+		parts[i] = low + add
+		extra -= add
+	}
+	return parts
+}
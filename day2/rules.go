@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+)
+
+const (
+	increasing = "increasing"
+	decreasing = "decreasing"
+	// either allows a report to be all increasing or all decreasing.
+	either = "either"
+	// anyDirection allows a report to change direction.
+	anyDirection = "any"
+)
+
+// defaultPreset is the name of the preset holding the puzzle's rules.
+const defaultPreset = "puzzle"
+
+// SafetyRules describes what makes a report safe.
+type SafetyRules struct {
+	// MinStep and MaxStep bound the difference between two adjacent levels that are not equal.
+	MinStep int `json:"minStep"`
+	MaxStep int `json:"maxStep"`
+	// Direction is one of increasing, decreasing, either or any.
+	Direction string `json:"direction"`
+	// AllowEqual allows two adjacent levels to be equal.
+	AllowEqual bool `json:"allowEqual"`
+	// MaxSpan bounds the difference between the highest and lowest levels of a report. 0 means no limit.
+	MaxSpan int `json:"maxSpan"`
+	// Predicates are the names of registered predicates every safe report must also satisfy.
+	Predicates []string `json:"predicates"`
+}
+
+// Predicate is a custom check a safe report must satisfy.
+type Predicate func(levels []int) bool
+
+var predicates = map[string]Predicate{
+	// nonNegative requires every level to be zero or higher.
+	"nonNegative": func(levels []int) bool {
+		for _, level := range levels {
+			if level < 0 {
+				return false
+			}
+		}
+		return true
+	},
+}
+
+// RegisterPredicate makes a custom predicate available to SafetyRules under the given name.
+func RegisterPredicate(name string, predicate Predicate) {
+	predicates[name] = predicate
+}
+
+// PuzzleRules returns the rules described by the puzzle:
+// the levels are either all increasing or all decreasing, and adjacent levels differ by at least one and at most three.
+func PuzzleRules() SafetyRules {
+	return SafetyRules{
+		MinStep:   1,
+		MaxStep:   3,
+		Direction: either,
+	}
+}
+
+// Validate returns an error if the rules are inconsistent.
+func (r SafetyRules) Validate() error {
+	if r.MinStep < 0 {
+		return fmt.Errorf("min step %d cannot be negative", r.MinStep)
+	}
+
+	if r.MaxStep < r.MinStep {
+		return fmt.Errorf("max step %d cannot be lower than min step %d", r.MaxStep, r.MinStep)
+	}
+
+	if !slices.Contains([]string{increasing, decreasing, either, anyDirection}, r.Direction) {
+		return fmt.Errorf("unknown direction %q", r.Direction)
+	}
+
+	if r.MaxSpan < 0 {
+		return fmt.Errorf("max span %d cannot be negative", r.MaxSpan)
+	}
+
+	for _, name := range r.Predicates {
+		if _, ok := predicates[name]; !ok {
+			return fmt.Errorf("unknown predicate %q", name)
+		}
+	}
+
+	return nil
+}
+
+// patterns returns the patterns a report can follow under these rules.
+func (r SafetyRules) patterns() []string {
+	if r.Direction == either {
+		return []string{increasing, decreasing}
+	}
+	return []string{r.Direction}
+}
+
+// stepAllowed returns if moving from previous to level is allowed when following the pattern.
+func (r SafetyRules) stepAllowed(previous, level int, pattern string) bool {
+	return len(r.stepViolations(previous, level, pattern)) == 0
+}
+
+// stepViolations returns the kinds of violations caused by moving from previous to level when following the pattern.
+func (r SafetyRules) stepViolations(previous, level int, pattern string) []string {
+	if previous == level {
+		if r.AllowEqual {
+			return nil
+		}
+		return []string{flatStep}
+	}
+
+	var kinds []string
+	if (pattern == increasing && previous > level) || (pattern == decreasing && previous < level) {
+		kinds = append(kinds, directionChange)
+	}
+
+	diff := abs(previous - level)
+	if diff < r.MinStep {
+		kinds = append(kinds, stepTooSmall)
+	}
+
+	if diff > r.MaxStep {
+		kinds = append(kinds, stepTooLarge)
+	}
+
+	return kinds
+}
+
+// spanAllowed returns if the difference between the highest and lowest levels is within the max span.
+func (r SafetyRules) spanAllowed(lowest, highest int) bool {
+	return r.MaxSpan == 0 || highest-lowest <= r.MaxSpan
+}
+
+// failedPredicates returns the names of the custom predicates the levels do not satisfy.
+func (r SafetyRules) failedPredicates(levels []int) []string {
+	var failed []string
+	for _, name := range r.Predicates {
+		if !predicates[name](levels) {
+			failed = append(failed, name)
+		}
+	}
+	return failed
+}
+
+// loadRules returns the rules of the preset with the given name.
+// Presets are read from a JSON file mapping preset names to rules. If fileName is empty, only the puzzle preset is available.
+func loadRules(fileName string, preset string) (SafetyRules, error) {
+	presets := map[string]SafetyRules{
+		defaultPreset: PuzzleRules(),
+	}
+
+	if fileName != "" {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			return SafetyRules{}, err
+		}
+
+		var filePresets map[string]SafetyRules
+		if err := json.Unmarshal(data, &filePresets); err != nil {
+			return SafetyRules{}, fmt.Errorf("failed to parse rules file: %w", err)
+		}
+
+		for name, rules := range filePresets {
+			presets[name] = rules
+		}
+	}
+
+	rules, ok := presets[preset]
+	if !ok {
+		return SafetyRules{}, fmt.Errorf("unknown preset %q", preset)
+	}
+
+	return rules, nil
+}
+
+// ruleFlags holds the flags that override individual rules of a preset.
+type ruleFlags struct {
+	minStep    *int
+	maxStep    *int
+	direction  *string
+	allowEqual *bool
+	maxSpan    *int
+}
+
+// defineRuleFlags defines the flags that override individual rules of a preset.
+func defineRuleFlags() ruleFlags {
+	return ruleFlags{
+		minStep:    flag.Int("min-step", 0, "Override the minimum difference between adjacent levels."),
+		maxStep:    flag.Int("max-step", 0, "Override the maximum difference between adjacent levels."),
+		direction:  flag.String("direction", "", "Override the allowed direction: increasing, decreasing, either or any."),
+		allowEqual: flag.Bool("allow-equal", false, "Override whether adjacent levels can be equal."),
+		maxSpan:    flag.Int("max-span", 0, "Override the maximum difference between the highest and lowest levels, 0 for no limit."),
+	}
+}
+
+// apply overrides the rules with the flags that were set on the command line.
+func (f ruleFlags) apply(rules SafetyRules) SafetyRules {
+	flag.Visit(func(set *flag.Flag) {
+		switch set.Name {
+		case "min-step":
+			rules.MinStep = *f.minStep
+		case "max-step":
+			rules.MaxStep = *f.maxStep
+		case "direction":
+			rules.Direction = *f.direction
+		case "allow-equal":
+			rules.AllowEqual = *f.allowEqual
+		case "max-span":
+			rules.MaxSpan = *f.maxSpan
+		}
+	})
+
+	return rules
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
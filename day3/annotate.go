@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math/big"
+	"strings"
+	"text/tabwriter"
+)
+
+// Formats of the annotated memory dump.
+const (
+	annotateANSI = "ansi"
+	annotateHTML = "html"
+)
+
+// ANSI escape codes used to colour the memory dump.
+const (
+	ansiReset         = "\x1b[0m"
+	ansiEnabled       = "\x1b[1;32m"
+	ansiDisabled      = "\x1b[9;33m"
+	ansiControl       = "\x1b[1;36m"
+	ansiNoise         = "\x1b[2m"
+	ansiDisabledNoise = "\x1b[2;31m"
+)
+
+// ansiCodes maps the class of a token to its ANSI escape code.
+var ansiCodes = map[string]string{
+	"enabled":        ansiEnabled,
+	"disabled":       ansiDisabled,
+	"control":        ansiControl,
+	"noise":          ansiNoise,
+	"noise-disabled": ansiDisabledNoise,
+}
+
+// annotatedInstruction is one row of the listing of instructions.
+type annotatedInstruction struct {
+	offset  int64
+	text    string
+	control bool
+	enabled bool
+	product string
+}
+
+// Annotator renders corrupted memory with its valid instructions, disabled regions and noise highlighted,
+// followed by a listing of every instruction. The memory is rendered as it streams by, but the listing is
+// held until the end, so it takes memory proportional to the number of instructions.
+type Annotator struct {
+	registry     *Registry
+	format       string
+	w            *bufio.Writer
+	instructions []annotatedInstruction
+	// Consecutive noise of the same class is merged, up to maxPendingNoise bytes
+	pendingNoise       strings.Builder
+	pendingNoiseClass  string
+	pendingNoiseOffset int64
+}
+
+// maxPendingNoise is the most bytes of noise merged before they are rendered.
+const maxPendingNoise = 4096
+
+// NewAnnotator returns an annotator writing the dump to w in the given format, either ansi or html.
+func NewAnnotator(registry *Registry, w io.Writer, format string) (*Annotator, error) {
+	if format != annotateANSI && format != annotateHTML {
+		return nil, fmt.Errorf("unknown annotation format %q", format)
+	}
+
+	a := &Annotator{
+		registry: registry,
+		format:   format,
+		w:        bufio.NewWriter(w),
+	}
+
+	if format == annotateHTML {
+		fmt.Fprint(a.w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Corrupted memory</title>
+<style>
+pre { white-space: pre-wrap; word-break: break-all; }
+.enabled { color: #080; font-weight: bold; }
+.disabled { color: #a60; text-decoration: line-through; }
+.control { color: #088; font-weight: bold; }
+.noise { color: #aaa; }
+.noise-disabled { color: #aaa; background: #fdd; }
+table { border-collapse: collapse; }
+td, th { padding: 0 1em; text-align: left; }
+</style>
+</head>
+<body>
+<pre>`)
+	} else {
+		fmt.Fprintf(a.w, "Legend: %senabled%s %sdisabled%s %scontrol%s %snoise%s %sdisabled noise%s\n\n",
+			ansiEnabled, ansiReset, ansiDisabled, ansiReset, ansiControl, ansiReset, ansiNoise, ansiReset, ansiDisabledNoise, ansiReset)
+	}
+
+	return a, nil
+}
+
+// Annotate renders one token. enabled is true if the token is in an enabled region of memory.
+func (a *Annotator) Annotate(token Token, enabled bool) error {
+	if token.Noise {
+		class := "noise"
+		if !enabled {
+			class = "noise-disabled"
+		}
+
+		if a.pendingNoiseClass != class || a.pendingNoise.Len() >= maxPendingNoise {
+			if err := a.flushNoise(); err != nil {
+				return err
+			}
+			a.pendingNoiseClass = class
+			a.pendingNoiseOffset = token.Offset
+		}
+		a.pendingNoise.WriteString(token.Text)
+		return nil
+	}
+
+	instruction := annotatedInstruction{
+		offset:  token.Offset,
+		text:    token.Name + "(" + strings.Join(token.Args, ",") + ")",
+		control: a.registry.instructions[token.Name].Control,
+		enabled: enabled,
+		product: "-",
+	}
+
+	if token.Name == "mul" {
+		instruction.product = product(token.Args)
+	}
+	a.instructions = append(a.instructions, instruction)
+
+	class := "disabled"
+	if instruction.control {
+		class = "control"
+	} else if enabled {
+		class = "enabled"
+	}
+
+	if err := a.flushNoise(); err != nil {
+		return err
+	}
+	return a.render(class, token.Offset, instruction.text)
+}
+
+// flushNoise renders the noise merged so far.
+func (a *Annotator) flushNoise() error {
+	if a.pendingNoise.Len() == 0 {
+		return nil
+	}
+
+	err := a.render(a.pendingNoiseClass, a.pendingNoiseOffset, a.pendingNoise.String())
+	a.pendingNoise.Reset()
+	return err
+}
+
+// render writes text highlighted according to its class.
+func (a *Annotator) render(class string, offset int64, text string) error {
+	if a.format == annotateHTML {
+		_, err := fmt.Fprintf(a.w, `<span class="%s" title="offset %d">%s</span>`, class, offset, html.EscapeString(text))
+		return err
+	}
+
+	_, err := fmt.Fprint(a.w, ansiCodes[class], text, ansiReset)
+	return err
+}
+
+// Close writes the listing of every instruction and flushes the dump.
+func (a *Annotator) Close() error {
+	if err := a.flushNoise(); err != nil {
+		return err
+	}
+
+	if a.format == annotateHTML {
+		fmt.Fprint(a.w, "</pre>\n<table>\n<tr><th>Offset</th><th>Instruction</th><th>Enabled</th><th>Product</th></tr>\n")
+		for _, instruction := range a.instructions {
+			fmt.Fprintf(a.w, "<tr><td>%d</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+				instruction.offset, html.EscapeString(instruction.text), instruction.enabledText(), instruction.product)
+		}
+		fmt.Fprint(a.w, "</table>\n</body>\n</html>\n")
+	} else {
+		fmt.Fprint(a.w, "\n\n")
+		table := tabwriter.NewWriter(a.w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "OFFSET\tINSTRUCTION\tENABLED\tPRODUCT")
+		for _, instruction := range a.instructions {
+			fmt.Fprintf(table, "%d\t%s\t%s\t%s\n", instruction.offset, instruction.text, instruction.enabledText(), instruction.product)
+		}
+		if err := table.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return a.w.Flush()
+}
+
+// enabledText returns whether the instruction was enabled, or "-" for control instructions, which always run.
+func (i annotatedInstruction) enabledText() string {
+	if i.control {
+		return "-"
+	}
+	if i.enabled {
+		return "yes"
+	}
+	return "no"
+}
+
+// product returns the product of the operands, however large they are.
+func product(args []string) string {
+	result := big.NewInt(1)
+	for _, arg := range args {
+		num, ok := new(big.Int).SetString(arg, 10)
+		if !ok {
+			return "-"
+		}
+		result.Mul(result, num)
+	}
+	return result.String()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Arithmetic types the interpreter can compute with.
+const (
+	arithmeticInt64   = "int64"
+	arithmeticBig     = "big"
+	arithmeticModular = "mod"
+)
+
+// errOverflow is returned when a checked int64 computation overflows.
+var errOverflow = errors.New("int64 overflow")
+
+// Value is a number computed by the interpreter.
+type Value interface {
+	fmt.Stringer
+	slog.LogValuer
+}
+
+// Arithmetic is the value type the interpreter computes with.
+type Arithmetic interface {
+	// Parse converts an operand as written in memory into a value.
+	Parse(digits string) (Value, error)
+	// Zero returns the value sums start from.
+	Zero() Value
+	Add(a, b Value) (Value, error)
+	Mul(a, b Value) (Value, error)
+}
+
+// NewArithmetic returns the arithmetic with the given name. The modulus is only used by modular arithmetic.
+func NewArithmetic(name string, modulus string) (Arithmetic, error) {
+	switch name {
+	case arithmeticInt64:
+		return Int64Arithmetic{}, nil
+	case arithmeticBig:
+		return BigArithmetic{}, nil
+	case arithmeticModular:
+		m, ok := new(big.Int).SetString(modulus, 10)
+		if !ok || m.Sign() <= 0 {
+			return nil, fmt.Errorf("modulus %q must be a positive integer", modulus)
+		}
+		return ModularArithmetic{Modulus: m}, nil
+	}
+
+	return nil, fmt.Errorf("unknown arithmetic %q", name)
+}
+
+// Int64Value is a value of Int64Arithmetic.
+type Int64Value int64
+
+func (v Int64Value) String() string {
+	return strconv.FormatInt(int64(v), 10)
+}
+
+func (v Int64Value) LogValue() slog.Value {
+	return slog.Int64Value(int64(v))
+}
+
+// Int64Arithmetic computes with int64, returning an error instead of overflowing.
+type Int64Arithmetic struct{}
+
+func (Int64Arithmetic) Parse(digits string) (Value, error) {
+	num, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return Int64Value(num), nil
+}
+
+func (Int64Arithmetic) Zero() Value {
+	return Int64Value(0)
+}
+
+func (Int64Arithmetic) Add(a, b Value) (Value, error) {
+	x, y := a.(Int64Value), b.(Int64Value)
+	if (y > 0 && x > math.MaxInt64-y) || (y < 0 && x < math.MinInt64-y) {
+		return nil, fmt.Errorf("%w: %d + %d", errOverflow, x, y)
+	}
+	return x + y, nil
+}
+
+func (Int64Arithmetic) Mul(a, b Value) (Value, error) {
+	x, y := a.(Int64Value), b.(Int64Value)
+	if x == 0 || y == 0 {
+		return Int64Value(0), nil
+	}
+
+	product := x * y
+	if product/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+		return nil, fmt.Errorf("%w: %d * %d", errOverflow, x, y)
+	}
+	return product, nil
+}
+
+// BigValue is a value of BigArithmetic and ModularArithmetic.
+type BigValue struct {
+	*big.Int
+}
+
+func (v BigValue) LogValue() slog.Value {
+	if v.IsInt64() {
+		return slog.Int64Value(v.Int64())
+	}
+	return slog.StringValue(v.String())
+}
+
+// BigArithmetic computes with arbitrary-precision integers, so it never overflows.
+type BigArithmetic struct{}
+
+func (BigArithmetic) Parse(digits string) (Value, error) {
+	num, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid operand %q", digits)
+	}
+	return BigValue{num}, nil
+}
+
+func (BigArithmetic) Zero() Value {
+	return BigValue{new(big.Int)}
+}
+
+func (BigArithmetic) Add(a, b Value) (Value, error) {
+	return BigValue{new(big.Int).Add(a.(BigValue).Int, b.(BigValue).Int)}, nil
+}
+
+func (BigArithmetic) Mul(a, b Value) (Value, error) {
+	return BigValue{new(big.Int).Mul(a.(BigValue).Int, b.(BigValue).Int)}, nil
+}
+
+// ModularArithmetic computes with integers modulo Modulus.
+type ModularArithmetic struct {
+	Modulus *big.Int
+}
+
+func (m ModularArithmetic) Parse(digits string) (Value, error) {
+	num, err := BigArithmetic{}.Parse(digits)
+	if err != nil {
+		return nil, err
+	}
+	return m.reduce(num.(BigValue).Int), nil
+}
+
+func (m ModularArithmetic) Zero() Value {
+	return BigValue{new(big.Int)}
+}
+
+func (m ModularArithmetic) Add(a, b Value) (Value, error) {
+	return m.reduce(new(big.Int).Add(a.(BigValue).Int, b.(BigValue).Int)), nil
+}
+
+func (m ModularArithmetic) Mul(a, b Value) (Value, error) {
+	return m.reduce(new(big.Int).Mul(a.(BigValue).Int, b.(BigValue).Int)), nil
+}
+
+// reduce returns x modulo the modulus, between 0 and the modulus.
+func (m ModularArithmetic) reduce(x *big.Int) Value {
+	return BigValue{x.Mod(x, m.Modulus)}
+}
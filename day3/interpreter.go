@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Instruction describes an instruction of the corrupted-memory language.
+type Instruction struct {
+	// Name is what the instruction is called in memory, such as "mul".
+	Name string
+	// Arity is the number of operands the instruction takes.
+	Arity int
+	// Control instructions run even while the machine is disabled.
+	Control bool
+	// Exec carries out the instruction on the machine.
+	Exec func(m *Machine, args []Value) error
+}
+
+// Registry holds the instructions the lexer recognizes and the interpreter evaluates.
+type Registry struct {
+	instructions map[string]Instruction
+	// names are sorted longest first for matching
+	names []string
+	// firstBytes marks the bytes that can start an instruction
+	firstBytes [256]bool
+}
+
+// NewRegistry returns a registry without any instructions.
+func NewRegistry() *Registry {
+	return &Registry{
+		instructions: make(map[string]Instruction),
+	}
+}
+
+// DefaultRegistry returns a registry with the mul, do and don't instructions described by the puzzle.
+func DefaultRegistry() *Registry {
+	registry := NewRegistry()
+
+	for _, instruction := range []Instruction{
+		{
+			Name:  "mul",
+			Arity: 2,
+			Exec: func(m *Machine, args []Value) error {
+				product, err := m.Arithmetic.Mul(args[0], args[1])
+				if err != nil {
+					return err
+				}
+
+				m.Sum, err = m.Arithmetic.Add(m.Sum, product)
+				return err
+			},
+		},
+		{
+			Name:    "do",
+			Control: true,
+			Exec: func(m *Machine, args []Value) error {
+				m.Enabled = true
+				return nil
+			},
+		},
+		{
+			Name:    "don't",
+			Control: true,
+			Exec: func(m *Machine, args []Value) error {
+				if m.Conditional {
+					m.Enabled = false
+				}
+				return nil
+			},
+		},
+	} {
+		// The default instructions are always valid
+		_ = registry.Register(instruction)
+	}
+
+	return registry
+}
+
+// Register adds a new instruction to the registry.
+func (r *Registry) Register(instruction Instruction) error {
+	if instruction.Name == "" || strings.ContainsAny(instruction.Name, "(),") {
+		return fmt.Errorf("invalid instruction name %q", instruction.Name)
+	}
+
+	if instruction.Arity < 0 {
+		return fmt.Errorf("instruction %s cannot have negative arity %d", instruction.Name, instruction.Arity)
+	}
+
+	if instruction.Exec == nil {
+		return fmt.Errorf("instruction %s has no semantics", instruction.Name)
+	}
+
+	if _, ok := r.instructions[instruction.Name]; ok {
+		return fmt.Errorf("instruction %s is already registered", instruction.Name)
+	}
+
+	r.instructions[instruction.Name] = instruction
+	r.firstBytes[instruction.Name[0]] = true
+	r.names = append(r.names, instruction.Name)
+	sort.SliceStable(r.names, func(i, j int) bool {
+		return len(r.names[i]) > len(r.names[j])
+	})
+
+	return nil
+}
+
+// Machine evaluates instructions, keeping track of whether they are enabled.
+type Machine struct {
+	registry *Registry
+	// Arithmetic is the value type operands and results are computed with.
+	Arithmetic Arithmetic
+	// Conditional machines honour don't() instructions. Otherwise every instruction stays enabled.
+	Conditional bool
+	// Enabled is false while instructions other than control instructions are skipped.
+	Enabled bool
+	// Sum is the sum of the results of every enabled mul instruction.
+	Sum Value
+}
+
+// NewMachine returns an enabled machine evaluating the instructions of the registry with the given arithmetic.
+func NewMachine(registry *Registry, arithmetic Arithmetic, conditional bool) *Machine {
+	return &Machine{
+		registry:    registry,
+		Arithmetic:  arithmetic,
+		Conditional: conditional,
+		Enabled:     true,
+		Sum:         arithmetic.Zero(),
+	}
+}
+
+// Execute evaluates one instruction. It returns true if the instruction ran.
+func (m *Machine) Execute(token Token) (bool, error) {
+	instruction, ok := m.registry.instructions[token.Name]
+	if !ok {
+		return false, fmt.Errorf("unknown instruction %s at offset %d", token.Name, token.Offset)
+	}
+
+	if !m.Enabled && !instruction.Control {
+		return false, nil
+	}
+
+	args := make([]Value, len(token.Args))
+	for index, arg := range token.Args {
+		num, err := m.Arithmetic.Parse(arg)
+		if err != nil {
+			return false, fmt.Errorf("instruction %s at offset %d: %w", token.Name, token.Offset, err)
+		}
+		args[index] = num
+	}
+
+	if err := instruction.Exec(m, args); err != nil {
+		return false, fmt.Errorf("instruction %s at offset %d: %w", token.Name, token.Offset, err)
+	}
+
+	return true, nil
+}
+
+// Evaluate streams every instruction from the lexer through each machine in order.
+// If observe is set, it is called with every token, including noise, along with whether each machine ran it.
+func Evaluate(lexer *Lexer, observe func(token Token, ran []bool) error, machines ...*Machine) error {
+	ran := make([]bool, len(machines))
+	for {
+		token, err := lexer.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for index, machine := range machines {
+			ran[index] = false
+			if token.Noise {
+				continue
+			}
+
+			if ran[index], err = machine.Execute(token); err != nil {
+				return err
+			}
+		}
+
+		if observe != nil {
+			if err := observe(token, ran); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+const (
+	// minBufferSize is the smallest buffer the lexer can stream memory with.
+	minBufferSize = 64
+	// strictMaxDigits is the most digits an operand can have according to the puzzle.
+	strictMaxDigits = 3
+	// maxNearMissLength is the most bytes of a near miss that are reported.
+	maxNearMissLength = 32
+)
+
+// LexerOptions configures how the lexer scans memory.
+type LexerOptions struct {
+	// BufferSize is the size in bytes of the buffer used to stream memory.
+	BufferSize int
+	// Strict only accepts operands of 1 to 3 digits, as the puzzle describes.
+	Strict bool
+	// OnNearMiss is called for every candidate instruction that was rejected, if set.
+	OnNearMiss func(NearMiss)
+	// EmitNoise also returns the bytes between instructions as noise tokens.
+	EmitNoise bool
+}
+
+// NearMiss is a candidate instruction that starts with an instruction name but is not well-formed.
+type NearMiss struct {
+	// Offset is the byte offset of the start of the candidate in memory.
+	Offset int64 `json:"offset"`
+	// Text is the candidate up to and including the byte where it was rejected.
+	Text string `json:"text"`
+	// Reason explains why the candidate was rejected.
+	Reason string `json:"reason"`
+}
+
+// Token is a well-formed instruction found in the corrupted memory, or a run of noise between instructions.
+type Token struct {
+	// Noise is true if the token is not an instruction, in which case Text holds the bytes of memory.
+	Noise bool   `json:"noise,omitempty"`
+	Text  string `json:"text,omitempty"`
+	// Name is the name of the instruction, such as "mul".
+	Name string `json:"name"`
+	// Args are the operands of the instruction as written in memory.
+	Args []string `json:"args"`
+	// Offset is the byte offset of the start of the instruction in memory, and Length is its length in bytes.
+	Offset int64 `json:"offset"`
+	Length int   `json:"length"`
+}
+
+// Lexer scans corrupted memory for well-formed instructions, skipping everything else.
+// Memory is streamed through a fixed-size buffer, so it can be any size. Instructions can span
+// reads from the underlying reader, but an instruction longer than half the buffer is not recognized.
+type Lexer struct {
+	registry *Registry
+	options  LexerOptions
+	reader   *bufio.Reader
+	// window is how many bytes are looked at when matching an instruction
+	window int
+	// offset is the byte offset of the start of the unread memory
+	offset int64
+}
+
+// NewLexer returns a lexer recognizing the instructions of the registry in the memory read from r.
+func NewLexer(registry *Registry, r io.Reader, options LexerOptions) (*Lexer, error) {
+	if options.BufferSize < minBufferSize {
+		return nil, fmt.Errorf("buffer size %d is smaller than %d", options.BufferSize, minBufferSize)
+	}
+
+	return &Lexer{
+		registry: registry,
+		options:  options,
+		reader:   bufio.NewReaderSize(r, options.BufferSize),
+		window:   options.BufferSize / 2,
+	}, nil
+}
+
+// Next returns the next instruction in memory. If noise is emitted, the noise before an instruction is returned first,
+// in tokens of at most half the buffer. It returns io.EOF once the end of memory is reached.
+func (l *Lexer) Next() (Token, error) {
+	for {
+		// Peeking at most half the buffer means the buffer is refilled at most once per half buffer consumed
+		b, err := l.reader.Peek(l.window)
+		if len(b) == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return Token{}, err
+		}
+
+		if err != nil && err != io.EOF {
+			return Token{}, err
+		}
+
+		token, miss, ok := l.match(b, err == io.EOF)
+		if ok {
+			token.Offset = l.offset
+			l.skip(token.Length)
+			return token, nil
+		}
+
+		if miss != nil && l.options.OnNearMiss != nil {
+			miss.Offset = l.offset
+			l.options.OnNearMiss(*miss)
+		}
+
+		// Not the start of an instruction, so skip ahead to the next byte that could start one
+		skip := 1
+		for skip < len(b) && !l.registry.firstBytes[b[skip]] {
+			skip++
+		}
+
+		if l.options.EmitNoise {
+			token := Token{Noise: true, Text: string(b[:skip]), Offset: l.offset, Length: skip}
+			l.skip(skip)
+			return token, nil
+		}
+		l.skip(skip)
+	}
+}
+
+// skip moves past n bytes of memory that have already been peeked at.
+func (l *Lexer) skip(n int) {
+	// Discard cannot fail for bytes that are already buffered
+	_, _ = l.reader.Discard(n)
+	l.offset += int64(n)
+}
+
+// match returns the instruction at the very start of b, if b starts with one.
+// The longest instruction name is tried first, so names that are prefixes of others do not shadow them.
+// If b starts with an instruction name but no instruction matches, it returns why the longest name was rejected.
+func (l *Lexer) match(b []byte, atEOF bool) (Token, *NearMiss, bool) {
+	if !l.registry.firstBytes[b[0]] {
+		return Token{}, nil, false
+	}
+
+	maxDigits := 0
+	if l.options.Strict {
+		maxDigits = strictMaxDigits
+	}
+
+	var miss *NearMiss
+	for _, name := range l.registry.names {
+		if len(b) < len(name) || string(b[:len(name)]) != name {
+			continue
+		}
+
+		args, length, reason := matchArgs(b[len(name):], l.registry.instructions[name].Arity, maxDigits, atEOF)
+		if reason == "" {
+			return Token{Name: name, Args: args, Length: len(name) + length}, nil, true
+		}
+
+		if miss == nil {
+			end := min(len(name)+length+1, len(b), maxNearMissLength)
+			miss = &NearMiss{
+				Text:   string(b[:end]),
+				Reason: fmt.Sprintf("%s: %s", name, reason),
+			}
+		}
+	}
+
+	return Token{}, miss, false
+}
+
+// matchArgs matches a parenthesized list of arity numbers separated by commas at the start of b.
+// Operands can have at most maxDigits digits, or any number of digits if maxDigits is 0.
+// It returns the numbers and the length of the list in bytes. If the list is not well-formed,
+// it returns the position where it was rejected instead, along with the reason.
+func matchArgs(b []byte, arity int, maxDigits int, atEOF bool) ([]string, int, string) {
+	pos := 0
+
+	// expect returns why the byte at pos is not the one wanted
+	expect := func(want string) string {
+		if pos < len(b) {
+			return fmt.Sprintf("expected %s, found %q", want, b[pos])
+		}
+		if atEOF {
+			return fmt.Sprintf("expected %s, found end of memory", want)
+		}
+		return "instruction is longer than the lexer window"
+	}
+
+	if pos >= len(b) || b[pos] != '(' {
+		return nil, pos, expect("'('")
+	}
+	pos++
+
+	args := make([]string, 0, arity)
+	for i := 0; i < arity; i++ {
+		if i > 0 {
+			if pos >= len(b) || b[pos] != ',' {
+				return nil, pos, expect("','")
+			}
+			pos++
+		}
+
+		start := pos
+		for pos < len(b) && isDigit(b[pos]) {
+			pos++
+		}
+
+		if pos == start {
+			return nil, pos, expect("a digit")
+		}
+
+		if maxDigits > 0 && pos-start > maxDigits {
+			return nil, pos - 1, fmt.Sprintf("operand %s has %d digits, at most %d allowed", b[start:pos], pos-start, maxDigits)
+		}
+		args = append(args, string(b[start:pos]))
+	}
+
+	if pos >= len(b) || b[pos] != ')' {
+		return nil, pos, expect("')'")
+	}
+	pos++
+
+	return args, pos, ""
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
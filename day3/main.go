@@ -1,13 +1,9 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"log/slog"
 	"os"
-	"regexp"
-	"strconv"
-	"strings"
 )
 
 func main() {
@@ -15,13 +11,34 @@ func main() {
 
 	// Get input file name
 	inputFlag := flag.String("input", "input/test_input.txt", "A file containing puzzle inputs.")
+	bufferFlag := flag.Int("buffer", 64*1024, "The size in bytes of the buffer used to stream memory. Instructions longer than half of it are not recognized.")
+	strictFlag := flag.Bool("strict", false, "Only accept operands of 1 to 3 digits, as the puzzle describes.")
+	nearMissesFlag := flag.Bool("near-misses", false, "Report candidate instructions that were rejected, and why.")
+	annotateFlag := flag.String("annotate", "", "Render the memory with its instructions highlighted, in ansi or html.")
+	annotateOutputFlag := flag.String("annotate-output", "", "A file to write the annotated memory to. Otherwise, it is written to stdout.")
+	arithmeticFlag := flag.String("arithmetic", arithmeticInt64, "The arithmetic to compute with: int64 (with overflow checks), big or mod.")
+	modulusFlag := flag.String("modulus", "1000000007", "The modulus used by mod arithmetic.")
 	flag.Parse()
 
+	// Without an output file, the annotated memory goes to stdout, so the logs go to stderr
+	if *annotateFlag != "" && *annotateOutputFlag == "" {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+
 	inputFileName := *inputFlag
+	bufferSize := *bufferFlag
 	logger = logger.With(
 		slog.String("inputFileName", inputFileName),
+		slog.Bool("strict", *strictFlag),
+		slog.String("arithmetic", *arithmeticFlag),
 	)
 
+	arithmetic, err := NewArithmetic(*arithmeticFlag, *modulusFlag)
+	if err != nil {
+		logger.Error("failed to set up arithmetic", "error", err)
+		os.Exit(1)
+	}
+
 	// Open input file
 	file, err := os.Open(inputFileName)
 	if err != nil {
@@ -30,68 +47,89 @@ func main() {
 	}
 	defer file.Close()
 
-	var (
-		allLines string
-		sumPart1 int64
-		sumPart2 int64
-	)
-
-	// Combine all lines from input file into one string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		allLines += scanner.Text()
+	// Stream the memory through the lexer
+	var nearMissCount int
+	options := LexerOptions{
+		BufferSize: bufferSize,
+		Strict:     *strictFlag,
+	}
+	if *nearMissesFlag {
+		options.OnNearMiss = func(miss NearMiss) {
+			nearMissCount++
+			logger.Info("near miss", "offset", miss.Offset, "text", miss.Text, "reason", miss.Reason)
+		}
 	}
 
-	// Part 1
-	sumPart1, err = calculateSum(allLines)
+	options.EmitNoise = *annotateFlag != ""
+
+	registry := DefaultRegistry()
+	lexer, err := NewLexer(registry, file, options)
 	if err != nil {
-		logger.Error("failed to calculate sum for part 1", "error", err)
+		logger.Error("failed to create lexer", "error", err)
 		os.Exit(1)
 	}
 
+	// Part 1
+	// Every mul instruction counts, so do() and don't() are ignored
+	part1Machine := NewMachine(registry, arithmetic, false)
+
 	// Part 2
-	// Split entire file by "do()" into sub strings
-	subStrings := strings.Split(allLines, "do()")
-	for _, subString := range subStrings {
-		// Split each sub string by "don't()" into further sub strings
-		subSubStrings := strings.Split(subString, "don't()")
-		// Only need to calculate sum for the first portion of sub string that does not follow "don't()"
-		subSum, err := calculateSum(subSubStrings[0])
+	// Only mul instructions enabled by the most recent do() or don't() count
+	part2Machine := NewMachine(registry, arithmetic, true)
+
+	// Annotate the memory as it is evaluated
+	var observe func(Token, []bool) error
+	var annotator *Annotator
+	if *annotateFlag != "" {
+		output := os.Stdout
+		if *annotateOutputFlag != "" {
+			output, err = os.Create(*annotateOutputFlag)
+			if err != nil {
+				logger.Error("failed to create annotation file", "error", err, "annotation file", *annotateOutputFlag)
+				os.Exit(1)
+			}
+			defer output.Close()
+		}
+
+		annotator, err = NewAnnotator(registry, output, *annotateFlag)
 		if err != nil {
-			logger.Error("failed to calculate sub sum for part 2", "error", err, "sub sub string", subSubStrings[0])
+			logger.Error("failed to create annotator", "error", err)
 			os.Exit(1)
 		}
 
-		sumPart2 += subSum
+		observe = func(token Token, ran []bool) error {
+			// Instructions are enabled if part 2 ran them, and noise is enabled if part 2 is currently enabled
+			enabled := part2Machine.Enabled
+			if !token.Noise {
+				enabled = ran[1]
+			}
+			return annotator.Annotate(token, enabled)
+		}
 	}
 
+	// Evaluate both parts in a single pass over the memory
+	if err := Evaluate(lexer, observe, part1Machine, part2Machine); err != nil {
+		logger.Error("failed to evaluate memory", "error", err)
+		os.Exit(1)
+	}
+
+	if annotator != nil {
+		if err := annotator.Close(); err != nil {
+			logger.Error("failed to write annotated memory", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	sumPart1 := part1Machine.Sum
+	sumPart2 := part2Machine.Sum
+
 	// Part 1 Solution
 	logger.Info("result #1 is ready!", "sum part 1", sumPart1)
 
 	// Part 2 Solution
 	logger.Info("result #2 is ready!", "sum part 2", sumPart2)
-}
-
-func calculateSum(line string) (int64, error) {
-	var sum int64
 
-	// Regex to find mul(X,Y)
-	reMul := regexp.MustCompile(`(?:mul\()(\d+)(?:,)(\d+)(?:\))`)
-	matches := reMul.FindAllStringSubmatch(line, -1)
-
-	for _, match := range matches {
-		num1, err := strconv.Atoi(match[1])
-		if err != nil {
-			return 0, err
-		}
-
-		num2, err := strconv.Atoi(match[2])
-		if err != nil {
-			return 0, err
-		}
-
-		sum += int64(num1) * int64(num2)
+	if *nearMissesFlag {
+		logger.Info("near misses are ready!", "near miss count", nearMissCount)
 	}
-
-	return sum, nil
 }
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// defaultAlphabet is the letters the generator fills the grid with.
+const defaultAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// maxPlacementTries is how many random positions are tried for one occurrence before an attempt is given up.
+const maxPlacementTries = 1000
+
+// WordCount is a word and how many times it should occur in a generated grid.
+type WordCount struct {
+	Word  string
+	Count int
+}
+
+// ShapeCount is a template and how many times it should occur in a generated grid, counting all its variants.
+type ShapeCount struct {
+	Template Template
+	Count    int
+}
+
+// GenerateOptions configures the puzzle generator.
+type GenerateOptions struct {
+	Rows int
+	Cols int
+	// Words are placed in the given directions. If no directions are given, all eight directions are used.
+	Words      []WordCount
+	Directions []Direction
+	// Shapes are placed in any of their rotations and reflections.
+	Shapes []ShapeCount
+	// Alphabet is the letters the rest of the grid is filled with. If empty, A to Z are used.
+	Alphabet []string
+	Seed     int64
+	// Attempts is how many grids are tried before giving up. If zero, 100 are tried.
+	Attempts int
+}
+
+// Puzzle is a generated grid along with its verified match counts.
+type Puzzle struct {
+	Grid        [][]string
+	WordCounts  map[string]int
+	ShapeCounts map[string]int
+	// Attempts is the number of grids tried, including the one returned.
+	Attempts int
+}
+
+// placement is one occurrence to place in the grid, either a word or a shape.
+type placement struct {
+	letters  []string
+	variants []Template
+}
+
+// Generate builds a grid in which each word and shape occurs exactly the requested number of times, as counted
+// by Search in the given directions and by MatchTemplate. Occurrences are placed at random, overlapping where their
+// letters agree but never completing another match, then every other cell is filled with a letter that does not
+// complete a match either. Every grid is counted again before it is returned, and a new one is tried if a placement
+// runs out of room. Some requests cannot be met, such as a palindrome occurring once in all directions.
+func Generate(options GenerateOptions) (Puzzle, error) {
+	if options.Rows <= 0 || options.Cols <= 0 {
+		return Puzzle{}, fmt.Errorf("grid size %dx%d must be positive", options.Rows, options.Cols)
+	}
+
+	if len(options.Directions) == 0 {
+		options.Directions = directions
+	}
+	if len(options.Alphabet) == 0 {
+		options.Alphabet = splitGraphemes(defaultAlphabet)
+	}
+	if options.Attempts == 0 {
+		options.Attempts = 100
+	}
+
+	var words []string
+	for _, word := range options.Words {
+		if word.Word == "" || word.Count < 0 {
+			return Puzzle{}, fmt.Errorf("word %q must not be empty and its count %d must not be negative", word.Word, word.Count)
+		}
+		words = append(words, word.Word)
+	}
+	for _, shape := range options.Shapes {
+		if shape.Count < 0 {
+			return Puzzle{}, fmt.Errorf("shape %q count %d must not be negative", shape.Template, shape.Count)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(options.Seed))
+
+	var err error
+	for attempt := 1; attempt <= options.Attempts; attempt++ {
+		var grid [][]string
+		grid, err = generateGrid(options, rng)
+		if err != nil {
+			continue
+		}
+
+		puzzle := Puzzle{
+			Grid:        grid,
+			WordCounts:  countMatches(Search(grid, words, SearchOptions{Directions: options.Directions})),
+			ShapeCounts: make(map[string]int),
+			Attempts:    attempt,
+		}
+		for _, shape := range options.Shapes {
+			puzzle.ShapeCounts[shape.Template.String()] = len(MatchTemplate(grid, shape.Template))
+		}
+
+		if err = puzzle.verify(options); err == nil {
+			return puzzle, nil
+		}
+	}
+
+	return Puzzle{}, fmt.Errorf("no grid found after %d attempts: %w", options.Attempts, err)
+}
+
+// verify returns an error if the counts of the puzzle are not the requested ones.
+func (p Puzzle) verify(options GenerateOptions) error {
+	var errs []error
+	for _, word := range options.Words {
+		if count := p.WordCounts[word.Word]; count != word.Count {
+			errs = append(errs, fmt.Errorf("word %q occurs %d times instead of %d", word.Word, count, word.Count))
+		}
+	}
+	for _, shape := range options.Shapes {
+		if count := p.ShapeCounts[shape.Template.String()]; count != shape.Count {
+			errs = append(errs, fmt.Errorf("shape %q occurs %d times instead of %d", shape.Template, count, shape.Count))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// generator holds the state of one attempt at generating a grid.
+type generator struct {
+	grid       [][]string
+	words      [][]string
+	directions []Direction
+	// variants holds the variants of every shape
+	variants []Template
+	rng      *rand.Rand
+}
+
+// occurrence identifies one match of a word or shape variant in the grid.
+type occurrence struct {
+	item string
+	row  int
+	col  int
+	// direction is the direction of a word, or empty for a shape variant
+	direction string
+}
+
+// generateGrid makes one attempt at placing every occurrence and filling the rest of the grid.
+func generateGrid(options GenerateOptions, rng *rand.Rand) ([][]string, error) {
+	g := &generator{
+		grid:       make([][]string, options.Rows),
+		directions: options.Directions,
+		rng:        rng,
+	}
+	for row := range g.grid {
+		g.grid[row] = make([]string, options.Cols)
+	}
+
+	var placements []placement
+	for _, word := range options.Words {
+		letters := splitGraphemes(word.Word)
+		g.words = append(g.words, letters)
+		for i := 0; i < word.Count; i++ {
+			placements = append(placements, placement{letters: letters})
+		}
+	}
+	for _, shape := range options.Shapes {
+		variants := shape.Template.Variants()
+		g.variants = append(g.variants, variants...)
+		for i := 0; i < shape.Count; i++ {
+			placements = append(placements, placement{variants: variants})
+		}
+	}
+	rng.Shuffle(len(placements), func(i, j int) {
+		placements[i], placements[j] = placements[j], placements[i]
+	})
+
+	for _, p := range placements {
+		if err := g.place(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return g.grid, g.fill(options.Alphabet)
+}
+
+// place writes the occurrence at a random position where it agrees with the letters already in the grid,
+// covers at least one blank cell and does not complete any other match.
+func (g *generator) place(p placement) error {
+	rows, cols := len(g.grid), len(g.grid[0])
+
+	for try := 0; try < maxPlacementTries; try++ {
+		var own occurrence
+		var cells []Cell
+		var letters []string
+
+		if p.variants != nil {
+			variant := p.variants[g.rng.Intn(len(p.variants))]
+			height, width := len(variant.Rows), len(variant.Rows[0])
+			if height > rows || width > cols {
+				return fmt.Errorf("shape %q does not fit in the grid", variant)
+			}
+
+			row, col := g.rng.Intn(rows-height+1), g.rng.Intn(cols-width+1)
+			own = occurrence{item: variant.String(), row: row, col: col}
+			cells = variant.cells(row, col)
+			for _, rowLetters := range variant.Rows {
+				for _, letter := range rowLetters {
+					if letter != wildcard {
+						letters = append(letters, letter)
+					}
+				}
+			}
+		} else {
+			direction := g.directions[g.rng.Intn(len(g.directions))]
+			end := len(p.letters) - 1
+			rowLow, rowHigh := max(0, -direction.DRow*end), min(rows, rows-direction.DRow*end)
+			colLow, colHigh := max(0, -direction.DCol*end), min(cols, cols-direction.DCol*end)
+			if rowLow >= rowHigh || colLow >= colHigh {
+				continue
+			}
+
+			row, col := rowLow+g.rng.Intn(rowHigh-rowLow), colLow+g.rng.Intn(colHigh-colLow)
+			own = occurrence{item: strings.Join(p.letters, ""), row: row, col: col, direction: direction.Name}
+			letters = p.letters
+			for i := range p.letters {
+				cells = append(cells, Cell{Row: row + i*direction.DRow, Col: col + i*direction.DCol})
+			}
+		}
+
+		// Only write the letters if they agree with the grid and fill at least one blank cell,
+		// otherwise the occurrence would lie on top of another one and not be counted
+		var blanks []Cell
+		fits := true
+		for i, cell := range cells {
+			existing := g.grid[cell.Row][cell.Col]
+			if existing == "" {
+				blanks = append(blanks, cell)
+			} else if existing != letters[i] {
+				fits = false
+				break
+			}
+		}
+		if !fits || len(blanks) == 0 {
+			continue
+		}
+
+		for i, cell := range cells {
+			g.grid[cell.Row][cell.Col] = letters[i]
+		}
+
+		// Every match completed by the new letters goes through one of the blank cells
+		accidental := false
+		seen := make(map[occurrence]bool)
+		for _, cell := range blanks {
+			for _, found := range g.occurrencesThrough(cell) {
+				if found != own && !seen[found] {
+					accidental = true
+				}
+				seen[found] = true
+			}
+		}
+		if !accidental {
+			return nil
+		}
+
+		for _, cell := range blanks {
+			g.grid[cell.Row][cell.Col] = ""
+		}
+	}
+
+	if p.variants != nil {
+		return fmt.Errorf("no room for shape %q", p.variants[0])
+	}
+	return fmt.Errorf("no room for word %q", strings.Join(p.letters, ""))
+}
+
+// fill writes a letter of the alphabet in every blank cell, choosing letters that do not complete a match.
+func (g *generator) fill(alphabet []string) error {
+	alphabet = append([]string(nil), alphabet...)
+	for row := range g.grid {
+		for col := range g.grid[row] {
+			if g.grid[row][col] != "" {
+				continue
+			}
+
+			g.rng.Shuffle(len(alphabet), func(i, j int) {
+				alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+			})
+
+			for _, letter := range alphabet {
+				g.grid[row][col] = letter
+				if len(g.occurrencesThrough(Cell{Row: row, Col: col})) == 0 {
+					break
+				}
+				g.grid[row][col] = ""
+			}
+
+			if g.grid[row][col] == "" {
+				return fmt.Errorf("every letter completes a match at row %d, col %d", row, col)
+			}
+		}
+	}
+
+	return nil
+}
+
+// occurrencesThrough returns every word and shape variant that now occurs through the cell. Words still missing
+// a letter are not returned, since they are checked again when that letter is written.
+func (g *generator) occurrencesThrough(cell Cell) []occurrence {
+	letter := g.grid[cell.Row][cell.Col]
+
+	var found []occurrence
+	for _, word := range g.words {
+		for i, wordLetter := range word {
+			if wordLetter != letter {
+				continue
+			}
+
+			for _, direction := range g.directions {
+				row, col := cell.Row-i*direction.DRow, cell.Col-i*direction.DCol
+				matches := true
+				for j, l := range word {
+					r, c := row+j*direction.DRow, col+j*direction.DCol
+					if r < 0 || r >= len(g.grid) || c < 0 || c >= len(g.grid[r]) || g.grid[r][c] != l {
+						matches = false
+						break
+					}
+				}
+				if matches {
+					found = append(found, occurrence{item: strings.Join(word, ""), row: row, col: col, direction: direction.Name})
+				}
+			}
+		}
+	}
+
+	// Wildcards match blank cells too, since they will match whatever letter is written there
+	for _, variant := range g.variants {
+		for r, letters := range variant.Rows {
+			for c, variantLetter := range letters {
+				row, col := cell.Row-r, cell.Col-c
+				if variantLetter == letter && row >= 0 && col >= 0 && variant.matchesAt(g.grid, row, col) {
+					found = append(found, occurrence{item: variant.String(), row: row, col: col})
+				}
+			}
+		}
+	}
+
+	return found
+}
+
+// parseCounts parses a comma-separated list of items with counts, such as "XMAS:3,SAMX:2".
+// An item without a count occurs once.
+func parseCounts(spec string) ([]string, []int, error) {
+	var items []string
+	var counts []int
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		item, count := entry, 1
+
+		if i := strings.LastIndex(entry, ":"); i >= 0 {
+			var err error
+			item = entry[:i]
+			count, err = strconv.Atoi(entry[i+1:])
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid count in %q: %w", entry, err)
+			}
+		}
+
+		items = append(items, item)
+		counts = append(counts, count)
+	}
+	return items, counts, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// zeroWidthJoiner joins the rune after it to the current grapheme.
+const zeroWidthJoiner = '\u200d'
+
+// ParseGrid reads a grid with one row per line and one letter per grapheme, so letters written with several
+// runes, such as an accent or an emoji sequence, take a single cell. Every row must have the same number of
+// letters. Blank lines at the end are ignored.
+func ParseGrid(r io.Reader) ([][]string, error) {
+	var grid [][]string
+	blankLines := 0
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if !utf8.ValidString(line) {
+			return nil, fmt.Errorf("line %d is not valid UTF-8", lineNumber)
+		}
+
+		if line == "" {
+			blankLines++
+			continue
+		}
+
+		if blankLines > 0 {
+			return nil, fmt.Errorf("line %d is blank", lineNumber-blankLines)
+		}
+
+		row := splitGraphemes(line)
+		if len(grid) > 0 && len(row) != len(grid[0]) {
+			return nil, fmt.Errorf("line %d has %d letters instead of %d, like line 1", lineNumber, len(row), len(grid[0]))
+		}
+
+		grid = append(grid, row)
+	}
+
+	return grid, scanner.Err()
+}
+
+// splitGraphemes splits text into graphemes. This covers what a word search needs rather than every rule of
+// Unicode text segmentation: combining marks and variation selectors stay with the rune before them, runes
+// joined by a zero width joiner stay together, and regional indicators pair up into flags.
+// Text is not normalized, so a precomposed "é" and "e" followed by a combining accent are different letters.
+func splitGraphemes(text string) []string {
+	var graphemes []string
+
+	start := 0
+	joined, regionalIndicators := false, 0
+	for i, r := range text {
+		extends := i > start && (joined || isExtending(r) || (isRegionalIndicator(r) && regionalIndicators%2 == 1))
+		if i > start && !extends {
+			graphemes = append(graphemes, text[start:i])
+			start = i
+			regionalIndicators = 0
+		}
+
+		joined = r == zeroWidthJoiner
+		if isRegionalIndicator(r) {
+			regionalIndicators++
+		}
+	}
+
+	if start < len(text) {
+		graphemes = append(graphemes, text[start:])
+	}
+
+	return graphemes
+}
+
+// isExtending returns if the rune belongs to the grapheme of the rune before it.
+func isExtending(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) || r == zeroWidthJoiner ||
+		unicode.Is(unicode.Variation_Selector, r) || (r >= 0x1f3fb && r <= 0x1f3ff) // skin tone modifiers
+}
+
+// isRegionalIndicator returns if the rune is one of the letters that pair up into flags.
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
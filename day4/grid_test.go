@@ -0,0 +1,186 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitGraphemes(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "ascii", text: "XMAS", want: []string{"X", "M", "A", "S"}},
+		{name: "precomposed accents", text: "CAFÉNIÑO", want: []string{"C", "A", "F", "É", "N", "I", "Ñ", "O"}},
+		{name: "combining marks", text: "CAFE\u0301N\u0303", want: []string{"C", "A", "F", "E\u0301", "N\u0303"}},
+		{name: "stacked combining marks", text: "a\u0301\u0323b", want: []string{"a\u0301\u0323", "b"}},
+		{name: "cjk", text: "漢字日本語", want: []string{"漢", "字", "日", "本", "語"}},
+		{name: "zero width joiner", text: "A👩\u200d💻B", want: []string{"A", "👩\u200d💻", "B"}},
+		{name: "joined family", text: "👨\u200d👩\u200d👧\u200d👦X", want: []string{"👨\u200d👩\u200d👧\u200d👦", "X"}},
+		{name: "skin tone and variation selector", text: "👍🏽❤\ufe0f", want: []string{"👍🏽", "❤\ufe0f"}},
+		{name: "regional indicators pair into flags", text: "🇫🇷🇩🇪🇯", want: []string{"🇫🇷", "🇩🇪", "🇯"}},
+		{name: "empty", text: "", want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := splitGraphemes(test.text); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("splitGraphemes(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseGrid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  [][]string
+	}{
+		{
+			name:  "combining marks",
+			input: "CAFE\u0301\nN\u0303IÑO\n",
+			want:  [][]string{{"C", "A", "F", "E\u0301"}, {"N\u0303", "I", "Ñ", "O"}},
+		},
+		{
+			name:  "zero width joiner",
+			input: "A👩\u200d💻\n🇫🇷B\n",
+			want:  [][]string{{"A", "👩\u200d💻"}, {"🇫🇷", "B"}},
+		},
+		{
+			name:  "blank lines at the end",
+			input: "漢字\n日本\n\n\n",
+			want:  [][]string{{"漢", "字"}, {"日", "本"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseGrid(strings.NewReader(test.input))
+			if err != nil {
+				t.Fatalf("ParseGrid returned an error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseGrid(%q) = %q, want %q", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseGridErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "ragged row", input: "XMAS\nXMA\nXMAS\n", want: "line 2 has 3 letters instead of 4, like line 1"},
+		{name: "ragged row with combining marks", input: "CAFE\u0301\nCAFEE\n", want: "line 2 has 5 letters instead of 4, like line 1"},
+		{name: "ragged cjk row", input: "漢字日\n漢字\n", want: "line 2 has 2 letters instead of 3, like line 1"},
+		{name: "blank line in the middle", input: "XMAS\n\nXMAS\n", want: "line 2 is blank"},
+		{name: "invalid utf-8", input: "XM\xffS\n", want: "line 1 is not valid UTF-8"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseGrid(strings.NewReader(test.input))
+			if err == nil || err.Error() != test.want {
+				t.Errorf("ParseGrid(%q) returned error %v, want %q", test.input, err, test.want)
+			}
+		})
+	}
+}
+
+// readGrid parses one of the example grids.
+func readGrid(t *testing.T, fileName string) [][]string {
+	t.Helper()
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", fileName, err)
+	}
+	defer file.Close()
+
+	grid, err := ParseGrid(file)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", fileName, err)
+	}
+	return grid
+}
+
+func TestAccentedGrid(t *testing.T) {
+	grid := readGrid(t, "input/accented_input.txt")
+	if len(grid) != 8 || len(grid[0]) != 10 {
+		t.Fatalf("grid is %dx%d, want 8x10", len(grid), len(grid[0]))
+	}
+
+	directions, err := parseDirections("right,down,down-right,left")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches := Search(grid, []string{"CAFÉ", "NIÑO"}, SearchOptions{Directions: directions})
+	want := map[string]int{"CAFÉ": 3, "NIÑO": 2}
+	if got := countMatches(matches); !reflect.DeepEqual(got, want) {
+		t.Errorf("match count by word = %v, want %v", got, want)
+	}
+
+	// An accented letter is one cell, so the word spans as many cells as it has letters
+	for _, match := range matches {
+		if len(match.Cells) != 4 {
+			t.Errorf("%s at row %d, col %d covers %d cells, want 4", match.Word, match.Row, match.Col, len(match.Cells))
+		}
+	}
+
+	// The same words written with combining accents are different letters, so they are not found
+	decomposed := Search(grid, []string{"CAFE\u0301", "NIN\u0303O"}, SearchOptions{Directions: directions})
+	if len(decomposed) != 0 {
+		t.Errorf("found %d matches of decomposed words, want 0", len(decomposed))
+	}
+}
+
+func TestCJKGrid(t *testing.T) {
+	grid := readGrid(t, "input/cjk_input.txt")
+	if len(grid) != 8 || len(grid[0]) != 8 {
+		t.Fatalf("grid is %dx%d, want 8x8", len(grid), len(grid[0]))
+	}
+
+	matches := Search(grid, []string{"漢字", "日本語"}, SearchOptions{})
+	want := map[string]int{"漢字": 4, "日本語": 3}
+	if got := countMatches(matches); !reflect.DeepEqual(got, want) {
+		t.Errorf("match count by word = %v, want %v", got, want)
+	}
+
+	shape, err := ParseTemplate("山.山/.川./文.文")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shapeMatches := MatchTemplate(grid, shape)
+	if len(shapeMatches) != 1 {
+		t.Fatalf("found %d shape matches, want 1", len(shapeMatches))
+	}
+	for _, cell := range shapeMatches[0].Cells {
+		if letter := grid[cell.Row][cell.Col]; !strings.Contains("山川文", letter) {
+			t.Errorf("shape match covers %q at row %d, col %d", letter, cell.Row, cell.Col)
+		}
+	}
+}
+
+func TestPuzzleExample(t *testing.T) {
+	grid := readGrid(t, "input/test_input.txt")
+
+	if got := len(Search(grid, []string{"XMAS"}, SearchOptions{})); got != 18 {
+		t.Errorf("XMAS count = %d, want 18", got)
+	}
+
+	template, err := ParseTemplate(xmasTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(MatchTemplate(grid, template)); got != 9 {
+		t.Errorf("X-MAS count = %d, want 9", got)
+	}
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 )
 
 func main() {
@@ -12,8 +13,63 @@ func main() {
 
 	// Get input file name
 	inputFlag := flag.String("input", "input/test_input.txt", "A file containing puzzle inputs.")
+	wordsFlag := flag.String("words", "", "A comma-separated list of words to search for, in addition to the puzzle.")
+	directionsFlag := flag.String("directions", "", "A comma-separated list of directions to search the words in. Otherwise, all eight directions are searched.")
+	shapeFlag := flag.String("shape", "", "A shape to search for, in addition to the puzzle, written as rows separated by / with . as a wildcard, such as M.S/.A./M.S.")
+	wrapFlag := flag.Bool("wrap", false, "Let words wrap around the edges of the grid.")
+	renderFlag := flag.String("render", "", "Render the grid with the matches highlighted, in dots, ansi, svg or png.")
+	renderMatchesFlag := flag.String("render-matches", "xmas", "The matches to render: xmas (part 1), x-mas (part 2), words or shape.")
+	renderOutputFlag := flag.String("render-output", "", "A file to write the rendered grid to. Otherwise, it is written to stdout. Required for png.")
+	generateFlag := flag.String("generate", "", "Generate a puzzle of the given size, such as 140x140, instead of solving one.")
+	generateWordsFlag := flag.String("generate-words", "XMAS:10", "A comma-separated list of words to place in the generated puzzle, each with its count, such as XMAS:10,SAMX:2.")
+	generateShapesFlag := flag.String("generate-shapes", "", "A comma-separated list of shapes to place in the generated puzzle, each with its count, such as M.S/.A./M.S:5.")
+	generateAlphabetFlag := flag.String("generate-alphabet", defaultAlphabet, "The letters to fill the rest of the generated puzzle with.")
+	generateSeedFlag := flag.Int64("generate-seed", 1, "The seed of the random generator, so the same puzzle can be generated again.")
+	generateOutputFlag := flag.String("generate-output", "", "A file to write the generated puzzle to. Otherwise, it is written to stdout.")
 	flag.Parse()
 
+	// Without an output file, the generated puzzle or rendered grid goes to stdout, so the logs go to stderr
+	if (*generateFlag != "" && *generateOutputFlag == "") || (*renderFlag != "" && *renderOutputFlag == "") {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+
+	// Generate a puzzle instead of solving one
+	if *generateFlag != "" {
+		options, err := generateOptions(*generateFlag, *generateWordsFlag, *generateShapesFlag, *directionsFlag)
+		if err != nil {
+			logger.Error("failed to parse generator options", "error", err)
+			os.Exit(1)
+		}
+		options.Alphabet = splitGraphemes(*generateAlphabetFlag)
+		options.Seed = *generateSeedFlag
+
+		puzzle, err := Generate(options)
+		if err != nil {
+			logger.Error("failed to generate puzzle", "error", err)
+			os.Exit(1)
+		}
+
+		output := os.Stdout
+		if *generateOutputFlag != "" {
+			output, err = os.Create(*generateOutputFlag)
+			if err != nil {
+				logger.Error("failed to create puzzle file", "error", err, "puzzle file", *generateOutputFlag)
+				os.Exit(1)
+			}
+			defer output.Close()
+		}
+
+		for _, row := range puzzle.Grid {
+			if _, err := fmt.Fprintln(output, strings.Join(row, "")); err != nil {
+				logger.Error("failed to write puzzle", "error", err)
+				os.Exit(1)
+			}
+		}
+
+		logger.Info("puzzle is generated!", "size", *generateFlag, "word counts", puzzle.WordCounts, "shape counts", puzzle.ShapeCounts, "attempts", puzzle.Attempts)
+		return
+	}
+
 	inputFileName := *inputFlag
 	logger = logger.With(
 		slog.String("inputFileName", inputFileName),
@@ -27,190 +83,168 @@ func main() {
 	}
 	defer file.Close()
 
-	var matrix [][]string
-
-	// Read input file line by line
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		row := make([]string, len(line))
-		for i, char := range line {
-			row[i] = string(char)
-		}
-
-		matrix = append(matrix, row)
+	// Read the grid, one letter per grapheme
+	matrix, err := ParseGrid(file)
+	if err != nil {
+		logger.Error("failed to parse grid", "error", err)
+		os.Exit(1)
 	}
 
 	// Part 1
-	var XMAScount int
-	for row := 0; row < len(matrix); row++ {
-		for col := 0; col < len(matrix[row]); col++ {
-			if matrix[row][col] == "X" {
-				XMAScount += checkXMAS(row, col, matrix)
-			}
-		}
-	}
+	XMASmatches := Search(matrix, []string{"XMAS"}, SearchOptions{})
+	XMAScount := len(XMASmatches)
 
 	// Part 1 Solution
 	logger.Info("result #1 is ready!", "XMAS count", XMAScount)
 
 	// Part 2
-	var MAScount int
-	for row := 0; row < len(matrix); row++ {
-		for col := 0; col < len(matrix[row]); col++ {
-			if matrix[row][col] == "A" && checkMAS(row, col, matrix) {
-				MAScount++
-			}
-		}
+	template, err := ParseTemplate(xmasTemplate)
+	if err != nil {
+		logger.Error("failed to parse X-MAS template", "error", err)
+		os.Exit(1)
 	}
+	MASmatches := MatchTemplate(matrix, template)
+	MAScount := len(MASmatches)
 
 	// Part 2 Solution
 	logger.Info("result #2 is ready!", "X-MAS count", MAScount)
-}
-
-func checkXMAS(row int, col int, matrix [][]string) int {
-	var count int
-
-	if checkHorizontalForward(row, col, matrix) {
-		count++
-	}
-
-	if checkHorizontalBackward(row, col, matrix) {
-		count++
-	}
-
-	if checkVerticalUpward(row, col, matrix) {
-		count++
-	}
-
-	if checkVerticalDownward(row, col, matrix) {
-		count++
-	}
-
-	if checkDiagonalUpwardRight(row, col, matrix) {
-		count++
-	}
-
-	if checkDiagonalDownwardRight(row, col, matrix) {
-		count++
-	}
-
-	if checkDiagonalUpwardLeft(row, col, matrix) {
-		count++
-	}
-
-	if checkDiagonalDownwardLeft(row, col, matrix) {
-		count++
-	}
 
-	return count
-}
-
-func checkHorizontalForward(row int, col int, matrix [][]string) bool {
-	if col+3 >= len(matrix[row]) {
-		return false
-	}
-
-	if matrix[row][col+1] == "M" && matrix[row][col+2] == "A" && matrix[row][col+3] == "S" {
-		return true
-	}
-
-	return false
-}
-
-func checkHorizontalBackward(row int, col int, matrix [][]string) bool {
-	if col-3 < 0 {
-		return false
-	}
-
-	if matrix[row][col-1] == "M" && matrix[row][col-2] == "A" && matrix[row][col-3] == "S" {
-		return true
-	}
-
-	return false
-}
+	// Search for other words
+	var wordMatches []Match
+	if *wordsFlag != "" {
+		searchDirections, err := parseDirections(*directionsFlag)
+		if err != nil {
+			logger.Error("failed to parse directions", "error", err)
+			os.Exit(1)
+		}
 
-func checkVerticalUpward(row int, col int, matrix [][]string) bool {
-	if row-3 < 0 {
-		return false
-	}
+		words := strings.Split(*wordsFlag, ",")
+		wordMatches = Search(matrix, words, SearchOptions{Directions: searchDirections, Wrap: *wrapFlag})
+		for _, match := range wordMatches {
+			logger.Info("word found", "word", match.Word, "row", match.Row, "col", match.Col, "direction", match.Direction)
+		}
 
-	if matrix[row-1][col] == "M" && matrix[row-2][col] == "A" && matrix[row-3][col] == "S" {
-		return true
+		logger.Info("word search is ready!", "match count", len(wordMatches), "match count by word", countMatches(wordMatches))
 	}
 
-	return false
-}
-
-func checkVerticalDownward(row int, col int, matrix [][]string) bool {
-	if row+3 >= len(matrix) {
-		return false
-	}
+	// Search for another shape
+	var shapeMatches []TemplateMatch
+	if *shapeFlag != "" {
+		shape, err := ParseTemplate(*shapeFlag)
+		if err != nil {
+			logger.Error("failed to parse shape", "error", err)
+			os.Exit(1)
+		}
 
-	if matrix[row+1][col] == "M" && matrix[row+2][col] == "A" && matrix[row+3][col] == "S" {
-		return true
-	}
+		shapeMatches = MatchTemplate(matrix, shape)
+		for _, match := range shapeMatches {
+			logger.Info("shape found", "row", match.Row, "col", match.Col, "variant", match.Variant)
+		}
 
-	return false
-}
+		logger.Info("shape search is ready!", "shape", shape.String(), "variant count", len(shape.Variants()), "match count", len(shapeMatches))
+	}
+
+	// Render the grid with the matches highlighted
+	if *renderFlag != "" {
+		var highlights []Highlight
+		switch *renderMatchesFlag {
+		case "xmas":
+			highlights = wordHighlights(XMASmatches)
+		case "x-mas":
+			highlights = templateHighlights(MASmatches)
+		case "words":
+			highlights = wordHighlights(wordMatches)
+		case "shape":
+			highlights = templateHighlights(shapeMatches)
+		default:
+			logger.Error("unknown matches to render", "render matches", *renderMatchesFlag)
+			os.Exit(1)
+		}
 
-func checkDiagonalUpwardRight(row int, col int, matrix [][]string) bool {
-	if row-3 < 0 || col+3 >= len(matrix[row]) {
-		return false
-	}
+		output := os.Stdout
+		if *renderOutputFlag != "" {
+			output, err = os.Create(*renderOutputFlag)
+			if err != nil {
+				logger.Error("failed to create render file", "error", err, "render file", *renderOutputFlag)
+				os.Exit(1)
+			}
+			defer output.Close()
+		} else if *renderFlag == renderPNG {
+			logger.Error("png rendering requires an output file")
+			os.Exit(1)
+		}
 
-	if matrix[row-1][col+1] == "M" && matrix[row-2][col+2] == "A" && matrix[row-3][col+3] == "S" {
-		return true
+		if err := Render(output, matrix, highlights, *renderFlag); err != nil {
+			logger.Error("failed to render grid", "error", err)
+			os.Exit(1)
+		}
 	}
-
-	return false
 }
 
-func checkDiagonalDownwardRight(row int, col int, matrix [][]string) bool {
-	if row+3 >= len(matrix) || col+3 >= len(matrix[row]) {
-		return false
+// wordHighlights returns a highlight with a line through each word match.
+func wordHighlights(matches []Match) []Highlight {
+	highlights := make([]Highlight, len(matches))
+	for i, match := range matches {
+		highlights[i] = Highlight{Cells: match.Cells, Line: true}
 	}
-
-	if matrix[row+1][col+1] == "M" && matrix[row+2][col+2] == "A" && matrix[row+3][col+3] == "S" {
-		return true
-	}
-
-	return false
+	return highlights
 }
 
-func checkDiagonalUpwardLeft(row int, col int, matrix [][]string) bool {
-	if row-3 < 0 || col-3 < 0 {
-		return false
-	}
-
-	if matrix[row-1][col-1] == "M" && matrix[row-2][col-2] == "A" && matrix[row-3][col-3] == "S" {
-		return true
+// templateHighlights returns a highlight marking the letters of each template match.
+func templateHighlights(matches []TemplateMatch) []Highlight {
+	highlights := make([]Highlight, len(matches))
+	for i, match := range matches {
+		highlights[i] = Highlight{Cells: match.Cells}
 	}
-
-	return false
+	return highlights
 }
 
-func checkDiagonalDownwardLeft(row int, col int, matrix [][]string) bool {
-	if row+3 >= len(matrix) || col-3 < 0 {
-		return false
+// generateOptions parses the generator flags: the size as ROWSxCOLS, the words and shapes with their counts,
+// and the directions to place words in.
+func generateOptions(size string, words string, shapes string, directionNames string) (GenerateOptions, error) {
+	var options GenerateOptions
+	if _, err := fmt.Sscanf(size, "%dx%d", &options.Rows, &options.Cols); err != nil {
+		return GenerateOptions{}, fmt.Errorf("invalid size %q: %w", size, err)
 	}
 
-	if matrix[row+1][col-1] == "M" && matrix[row+2][col-2] == "A" && matrix[row+3][col-3] == "S" {
-		return true
+	var err error
+	options.Directions, err = parseDirections(directionNames)
+	if err != nil {
+		return GenerateOptions{}, err
 	}
 
-	return false
-}
-
-func checkMAS(row int, col int, matrix [][]string) bool {
-	if row-1 < 0 || row+1 >= len(matrix) || col-1 < 0 || col+1 >= len(matrix[row]) {
-		return false
+	seen := make(map[string]bool)
+	if words != "" {
+		items, counts, err := parseCounts(words)
+		if err != nil {
+			return GenerateOptions{}, err
+		}
+		for i, word := range items {
+			if seen[word] {
+				return GenerateOptions{}, fmt.Errorf("word %q is listed twice", word)
+			}
+			seen[word] = true
+			options.Words = append(options.Words, WordCount{Word: word, Count: counts[i]})
+		}
 	}
 
-	if ((matrix[row-1][col-1] == "M" && matrix[row+1][col+1] == "S") || (matrix[row-1][col-1] == "S" && matrix[row+1][col+1] == "M")) && ((matrix[row-1][col+1] == "M" && matrix[row+1][col-1] == "S") || (matrix[row-1][col+1] == "S" && matrix[row+1][col-1] == "M")) {
-		return true
+	if shapes != "" {
+		items, counts, err := parseCounts(shapes)
+		if err != nil {
+			return GenerateOptions{}, err
+		}
+		for i, pattern := range items {
+			template, err := ParseTemplate(pattern)
+			if err != nil {
+				return GenerateOptions{}, err
+			}
+			if seen[template.String()] {
+				return GenerateOptions{}, fmt.Errorf("shape %q is listed twice", template)
+			}
+			seen[template.String()] = true
+			options.Shapes = append(options.Shapes, ShapeCount{Template: template, Count: counts[i]})
+		}
 	}
 
-	return false
+	return options, nil
 }
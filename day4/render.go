@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Formats the grid can be rendered in.
+const (
+	renderDots = "dots"
+	renderANSI = "ansi"
+	renderSVG  = "svg"
+	renderPNG  = "png"
+)
+
+// Sizes in pixels used when rendering images.
+const (
+	svgCellSize = 24
+	pngCellSize = 12
+)
+
+// Highlight is a set of cells to highlight together, such as the letters of one match.
+type Highlight struct {
+	Cells []Cell
+	// Line draws a line through the cells in order, as for a word. Otherwise, each cell is marked on its own.
+	Line bool
+}
+
+// palette holds the colours highlights cycle through.
+var palette = []color.RGBA{
+	{R: 0xe6, G: 0x19, B: 0x4b, A: 0xff},
+	{R: 0x3c, G: 0xb4, B: 0x4b, A: 0xff},
+	{R: 0x43, G: 0x63, B: 0xd8, A: 0xff},
+	{R: 0xf5, G: 0x82, B: 0x31, A: 0xff},
+	{R: 0x91, G: 0x1e, B: 0xb4, A: 0xff},
+	{R: 0x42, G: 0xd4, B: 0xf4, A: 0xff},
+}
+
+// ansiPalette holds the ANSI escape codes highlights cycle through.
+var ansiPalette = []string{"\x1b[1;31m", "\x1b[1;32m", "\x1b[1;34m", "\x1b[1;33m", "\x1b[1;35m", "\x1b[1;36m"}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiDim   = "\x1b[2m"
+)
+
+// Render writes the grid with the highlights in the given format: dots replaces every cell that is not highlighted
+// with ".", ansi colours each highlight, and svg and png draw an image with a line through each word.
+func Render(w io.Writer, grid [][]string, highlights []Highlight, format string) error {
+	switch format {
+	case renderDots, renderANSI:
+		return renderText(w, grid, highlights, format == renderANSI)
+	case renderSVG:
+		return renderSVGImage(w, grid, highlights)
+	case renderPNG:
+		return renderPNGImage(w, grid, highlights)
+	}
+
+	return fmt.Errorf("unknown render format %q", format)
+}
+
+// highlightOwners returns, for each highlighted cell, the index of the first highlight covering it.
+func highlightOwners(highlights []Highlight) map[Cell]int {
+	owners := make(map[Cell]int)
+	for index, highlight := range highlights {
+		for _, cell := range highlight.Cells {
+			if _, ok := owners[cell]; !ok {
+				owners[cell] = index
+			}
+		}
+	}
+	return owners
+}
+
+// gridWidth returns the length of the longest row.
+func gridWidth(grid [][]string) int {
+	width := 0
+	for _, row := range grid {
+		width = max(width, len(row))
+	}
+	return width
+}
+
+// adjacent returns if two cells touch, so a line can be drawn between them without crossing the grid.
+func adjacent(a, b Cell) bool {
+	return abs(a.Row-b.Row) <= 1 && abs(a.Col-b.Col) <= 1
+}
+
+func renderText(w io.Writer, grid [][]string, highlights []Highlight, colour bool) error {
+	owners := highlightOwners(highlights)
+	out := bufio.NewWriter(w)
+
+	for row, letters := range grid {
+		for col, letter := range letters {
+			owner, ok := owners[Cell{Row: row, Col: col}]
+			switch {
+			case !colour && !ok:
+				fmt.Fprint(out, placeholder(letter))
+			case !colour:
+				fmt.Fprint(out, letter)
+			case !ok:
+				fmt.Fprint(out, ansiDim, letter, ansiReset)
+			default:
+				fmt.Fprint(out, ansiPalette[owner%len(ansiPalette)], letter, ansiReset)
+			}
+		}
+		fmt.Fprintln(out)
+	}
+
+	return out.Flush()
+}
+
+// placeholder returns the dot replacing a letter, taking two columns like the letter if it is a CJK character.
+func placeholder(letter string) string {
+	r, _ := utf8.DecodeRuneInString(letter)
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		return "．"
+	}
+	return "."
+}
+
+func renderSVGImage(w io.Writer, grid [][]string, highlights []Highlight) error {
+	owners := highlightOwners(highlights)
+	out := bufio.NewWriter(w)
+	width, height := gridWidth(grid)*svgCellSize, len(grid)*svgCellSize
+
+	center := func(cell Cell) (int, int) {
+		return cell.Col*svgCellSize + svgCellSize/2, cell.Row*svgCellSize + svgCellSize/2
+	}
+
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="%d">`+"\n",
+		width, height, width, height, svgCellSize*2/3)
+	fmt.Fprintf(out, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+
+	// Draw the highlights under the letters
+	for index, highlight := range highlights {
+		c := palette[index%len(palette)]
+		stroke := fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+
+		for i, cell := range highlight.Cells {
+			if highlight.Line && i > 0 && adjacent(highlight.Cells[i-1], cell) {
+				x1, y1 := center(highlight.Cells[i-1])
+				x2, y2 := center(cell)
+				fmt.Fprintf(out, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="%d" stroke-linecap="round" stroke-opacity="0.4"/>`+"\n",
+					x1, y1, x2, y2, stroke, svgCellSize*2/3)
+			}
+
+			if !highlight.Line || len(highlight.Cells) == 1 {
+				x, y := center(cell)
+				fmt.Fprintf(out, `<circle cx="%d" cy="%d" r="%d" fill="%s" fill-opacity="0.4"/>`+"\n", x, y, svgCellSize/3, stroke)
+			}
+		}
+	}
+
+	for row, letters := range grid {
+		for col, letter := range letters {
+			fill := `fill="#bbbbbb"`
+			if _, ok := owners[Cell{Row: row, Col: col}]; ok {
+				fill = `fill="black" font-weight="bold"`
+			}
+
+			x, y := center(Cell{Row: row, Col: col})
+			fmt.Fprintf(out, `<text x="%d" y="%d" text-anchor="middle" dominant-baseline="central" %s>%s</text>`+"\n",
+				x, y, fill, html.EscapeString(letter))
+		}
+	}
+
+	fmt.Fprintln(out, "</svg>")
+	return out.Flush()
+}
+
+// renderPNGImage draws each cell as a square, since the standard library has no fonts to draw letters with.
+func renderPNGImage(w io.Writer, grid [][]string, highlights []Highlight) error {
+	owners := highlightOwners(highlights)
+	img := image.NewRGBA(image.Rect(0, 0, gridWidth(grid)*pngCellSize, len(grid)*pngCellSize))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	unmatched := image.NewUniform(color.RGBA{R: 0xdd, G: 0xdd, B: 0xdd, A: 0xff})
+	matched := image.NewUniform(color.RGBA{R: 0x55, G: 0x55, B: 0x55, A: 0xff})
+	for row, letters := range grid {
+		for col := range letters {
+			square := image.Rect(col*pngCellSize+2, row*pngCellSize+2, (col+1)*pngCellSize-2, (row+1)*pngCellSize-2)
+			fill := unmatched
+			if _, ok := owners[Cell{Row: row, Col: col}]; ok {
+				fill = matched
+			}
+			draw.Draw(img, square, fill, image.Point{}, draw.Src)
+		}
+	}
+
+	center := func(cell Cell) (int, int) {
+		return cell.Col*pngCellSize + pngCellSize/2, cell.Row*pngCellSize + pngCellSize/2
+	}
+
+	for index, highlight := range highlights {
+		c := palette[index%len(palette)]
+		for i, cell := range highlight.Cells {
+			if highlight.Line && i > 0 && adjacent(highlight.Cells[i-1], cell) {
+				x1, y1 := center(highlight.Cells[i-1])
+				x2, y2 := center(cell)
+				drawLine(img, x1, y1, x2, y2, c)
+			}
+
+			if !highlight.Line || len(highlight.Cells) == 1 {
+				x, y := center(cell)
+				drawLine(img, x, y, x, y, c)
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// drawLine draws a line three pixels thick from (x1, y1) to (x2, y2).
+func drawLine(img *image.RGBA, x1, y1, x2, y2 int, c color.RGBA) {
+	steps := max(abs(x2-x1), abs(y2-y1), 1)
+	for step := 0; step <= steps; step++ {
+		x := x1 + (x2-x1)*step/steps
+		y := y1 + (y2-y1)*step/steps
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				img.SetRGBA(x+dx, y+dy, c)
+			}
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
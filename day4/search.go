@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Direction is a step across the grid.
+type Direction struct {
+	Name string
+	DRow int
+	DCol int
+}
+
+// directions lists all eight directions a word can be written in.
+var directions = []Direction{
+	{Name: "right", DRow: 0, DCol: 1},
+	{Name: "left", DRow: 0, DCol: -1},
+	{Name: "up", DRow: -1, DCol: 0},
+	{Name: "down", DRow: 1, DCol: 0},
+	{Name: "up-right", DRow: -1, DCol: 1},
+	{Name: "down-right", DRow: 1, DCol: 1},
+	{Name: "up-left", DRow: -1, DCol: -1},
+	{Name: "down-left", DRow: 1, DCol: -1},
+}
+
+// parseDirections converts a comma-separated list of direction names into directions.
+// An empty list means all eight directions.
+func parseDirections(names string) ([]Direction, error) {
+	if names == "" {
+		return directions, nil
+	}
+
+	var parsed []Direction
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+
+		found := false
+		for _, direction := range directions {
+			if direction.Name == name {
+				parsed = append(parsed, direction)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown direction %q", name)
+		}
+	}
+
+	return parsed, nil
+}
+
+// SearchOptions configures a word search.
+type SearchOptions struct {
+	// Directions restricts the directions words are searched in. If empty, all eight directions are searched.
+	Directions []Direction
+	// Wrap lets words continue on the opposite edge of the grid.
+	Wrap bool
+}
+
+// Match is one occurrence of a word in the grid.
+type Match struct {
+	Word      string `json:"word"`
+	Row       int    `json:"row"`
+	Col       int    `json:"col"`
+	Direction string `json:"direction"`
+	// Cells are the positions of the letters of the word, in order.
+	Cells []Cell `json:"-"`
+}
+
+// Cell is a position in the grid.
+type Cell struct {
+	Row int
+	Col int
+}
+
+// trieNode is a node of a trie of the words being searched for, with one letter per edge.
+type trieNode struct {
+	children map[string]*trieNode
+	// word is set if a word ends at this node
+	word string
+}
+
+// newTrie builds a trie of the words, returning it along with the length of the longest word in letters.
+func newTrie(words []string) (*trieNode, int) {
+	root := &trieNode{children: make(map[string]*trieNode)}
+	longest := 0
+
+	for _, word := range words {
+		letters := splitGraphemes(word)
+		if len(letters) == 0 {
+			continue
+		}
+		longest = max(longest, len(letters))
+
+		node := root
+		for _, letter := range letters {
+			child, ok := node.children[letter]
+			if !ok {
+				child = &trieNode{children: make(map[string]*trieNode)}
+				node.children[letter] = child
+			}
+			node = child
+		}
+		node.word = word
+	}
+
+	return root, longest
+}
+
+// Search finds every occurrence of the words in the grid. All the words are searched for at once by walking
+// a trie of the words from each cell in each direction, so the cost does not grow with the number of words.
+// Matches are returned ordered by position, then direction, then word length.
+func Search(grid [][]string, words []string, options SearchOptions) []Match {
+	root, longest := newTrie(words)
+
+	searchDirections := options.Directions
+	if len(searchDirections) == 0 {
+		searchDirections = directions
+	}
+
+	var matches []Match
+	for row := 0; row < len(grid); row++ {
+		for col := 0; col < len(grid[row]); col++ {
+			if _, ok := root.children[grid[row][col]]; !ok {
+				continue
+			}
+
+			for _, direction := range searchDirections {
+				node := root
+				r, c := row, col
+				var cells []Cell
+
+				// With wrapping, stop after the longest word so the walk does not go around forever
+				for step := 0; step < longest; step++ {
+					if !options.Wrap && (r < 0 || r >= len(grid) || c < 0 || c >= len(grid[r])) {
+						break
+					}
+
+					if options.Wrap {
+						r = (r%len(grid) + len(grid)) % len(grid)
+						if len(grid[r]) == 0 {
+							break
+						}
+						c = (c%len(grid[r]) + len(grid[r])) % len(grid[r])
+					}
+
+					child, ok := node.children[grid[r][c]]
+					if !ok {
+						break
+					}
+					node = child
+					cells = append(cells, Cell{Row: r, Col: c})
+
+					if node.word != "" {
+						matches = append(matches, Match{
+							Word:      node.word,
+							Row:       row,
+							Col:       col,
+							Direction: direction.Name,
+							Cells:     append([]Cell(nil), cells...),
+						})
+					}
+
+					r += direction.DRow
+					c += direction.DCol
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+// countMatches returns the number of matches of each word.
+func countMatches(matches []Match) map[string]int {
+	counts := make(map[string]int)
+	for _, match := range matches {
+		counts[match.Word]++
+	}
+	return counts
+}
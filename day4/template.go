@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// wildcard matches any letter in a template.
+const wildcard = "."
+
+// xmasTemplate is the X-MAS cross shape from part 2.
+const xmasTemplate = "M.S/.A./M.S"
+
+// Template is a small 2D pattern of letters and wildcards.
+type Template struct {
+	Rows [][]string
+}
+
+// TemplateMatch is one occurrence of a template in the grid.
+type TemplateMatch struct {
+	// Row and Col are the position of the top left corner of the variant in the grid.
+	Row int `json:"row"`
+	Col int `json:"col"`
+	// Variant is the rotation or reflection of the template that matched, written like the template.
+	Variant string `json:"variant"`
+	// Cells are the positions of the letters of the variant, skipping wildcards.
+	Cells []Cell `json:"-"`
+}
+
+// ParseTemplate parses a template written as rows separated by "/", such as "M.S/.A./M.S", where "." is a wildcard.
+// Spaces around rows are ignored.
+func ParseTemplate(pattern string) (Template, error) {
+	var template Template
+	for _, row := range strings.Split(pattern, "/") {
+		letters := splitGraphemes(strings.TrimSpace(row))
+		if len(letters) == 0 {
+			return Template{}, fmt.Errorf("template %q has an empty row", pattern)
+		}
+
+		if len(template.Rows) > 0 && len(letters) != len(template.Rows[0]) {
+			return Template{}, fmt.Errorf("template %q has rows of different lengths", pattern)
+		}
+
+		template.Rows = append(template.Rows, letters)
+	}
+
+	return template, nil
+}
+
+// String writes the template the same way it is parsed.
+func (t Template) String() string {
+	rows := make([]string, len(t.Rows))
+	for i, row := range t.Rows {
+		rows[i] = strings.Join(row, "")
+	}
+	return strings.Join(rows, "/")
+}
+
+// rotate returns the template turned a quarter clockwise.
+func (t Template) rotate() Template {
+	height, width := len(t.Rows), len(t.Rows[0])
+	rotated := Template{Rows: make([][]string, width)}
+	for row := 0; row < width; row++ {
+		rotated.Rows[row] = make([]string, height)
+		for col := 0; col < height; col++ {
+			rotated.Rows[row][col] = t.Rows[height-1-col][row]
+		}
+	}
+	return rotated
+}
+
+// reflect returns the template mirrored left to right.
+func (t Template) reflect() Template {
+	reflected := Template{Rows: make([][]string, len(t.Rows))}
+	for row, letters := range t.Rows {
+		reflected.Rows[row] = make([]string, len(letters))
+		for col, letter := range letters {
+			reflected.Rows[row][len(letters)-1-col] = letter
+		}
+	}
+	return reflected
+}
+
+// Variants returns every distinct rotation and reflection of the template, starting with the template itself.
+func (t Template) Variants() []Template {
+	var variants []Template
+	seen := make(map[string]bool)
+
+	for _, start := range []Template{t, t.reflect()} {
+		variant := start
+		for turn := 0; turn < 4; turn++ {
+			if key := variant.String(); !seen[key] {
+				seen[key] = true
+				variants = append(variants, variant)
+			}
+			variant = variant.rotate()
+		}
+	}
+
+	return variants
+}
+
+// matchesAt returns if the template matches the grid with its top left corner at row and col.
+func (t Template) matchesAt(grid [][]string, row int, col int) bool {
+	if row+len(t.Rows) > len(grid) {
+		return false
+	}
+
+	for r, letters := range t.Rows {
+		if col+len(letters) > len(grid[row+r]) {
+			return false
+		}
+
+		for c, letter := range letters {
+			if letter != wildcard && grid[row+r][col+c] != letter {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// cells returns the positions of the letters of the template with its top left corner at row and col.
+func (t Template) cells(row int, col int) []Cell {
+	var cells []Cell
+	for r, letters := range t.Rows {
+		for c, letter := range letters {
+			if letter != wildcard {
+				cells = append(cells, Cell{Row: row + r, Col: col + c})
+			}
+		}
+	}
+	return cells
+}
+
+// MatchTemplate finds every occurrence of any rotation or reflection of the template in the grid.
+func MatchTemplate(grid [][]string, template Template) []TemplateMatch {
+	variants := template.Variants()
+
+	var matches []TemplateMatch
+	for row := 0; row < len(grid); row++ {
+		for col := 0; col < len(grid[row]); col++ {
+			for _, variant := range variants {
+				if variant.matchesAt(grid, row, col) {
+					matches = append(matches, TemplateMatch{
+						Row:     row,
+						Col:     col,
+						Variant: variant.String(),
+						Cells:   variant.cells(row, col),
+					})
+				}
+			}
+		}
+	}
+
+	return matches
+}
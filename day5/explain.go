@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats of the explanation of out of order updates.
+const (
+	explainText = "text"
+	explainJSON = "json"
+)
+
+// RuleViolation is a rule broken by an update, with the positions of both its pages, starting from 1.
+type RuleViolation struct {
+	Rule           string `json:"rule"`
+	Before         int    `json:"before"`
+	After          int    `json:"after"`
+	BeforePosition int    `json:"beforePosition"`
+	AfterPosition  int    `json:"afterPosition"`
+}
+
+// Explanation tells why an update is out of order and how to fix it.
+type Explanation struct {
+	Line       int             `json:"line"`
+	Pages      []int           `json:"pages"`
+	Ordered    bool            `json:"ordered"`
+	Violations []RuleViolation `json:"violations"`
+	// MustMove is a smallest set of pages to move to fix the update, in the order they are listed.
+	// All the other pages can keep their order.
+	MustMove []int `json:"mustMove"`
+	// Fixed is the update fixed by Sort.
+	Fixed []int `json:"fixed"`
+	// Error is set instead of MustMove and Fixed if the rules between the pages form a cycle.
+	Error string `json:"error,omitempty"`
+}
+
+// Explain lists every rule the update breaks, a smallest set of pages that must move to fix it, and a fixed order.
+func (g *RuleGraph) Explain(update Update) Explanation {
+	explanation := Explanation{
+		Line:       update.Line,
+		Pages:      update.Pages,
+		Violations: g.Violations(update.Pages),
+	}
+	explanation.Ordered = len(explanation.Violations) == 0
+
+	fixed, err := g.Sort(update.Pages)
+	if err != nil {
+		explanation.Error = err.Error()
+		return explanation
+	}
+	explanation.Fixed = fixed
+
+	explanation.MustMove = []int{}
+	for i, stays := range g.largestOrderedSubset(update.Pages) {
+		if !stays {
+			explanation.MustMove = append(explanation.MustMove, update.Pages[i])
+		}
+	}
+
+	return explanation
+}
+
+// Violations returns every rule broken by the pages, ordered by the position of the page that should come after.
+func (g *RuleGraph) Violations(pages []int) []RuleViolation {
+	position := make(map[int]int, len(pages))
+	for i, page := range pages {
+		position[page] = i
+	}
+
+	restricted := g.Restrict(pages)
+	violations := []RuleViolation{}
+	for afterPosition, page := range pages {
+		for _, prerequisite := range restricted[page] {
+			if beforePosition := position[prerequisite]; beforePosition > afterPosition {
+				violations = append(violations, RuleViolation{
+					Rule:           fmt.Sprintf("%d|%d", prerequisite, page),
+					Before:         prerequisite,
+					After:          page,
+					BeforePosition: beforePosition + 1,
+					AfterPosition:  afterPosition + 1,
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// precedes returns, for each pair of pages, if the rules between the pages require the first before the second,
+// directly or through other pages.
+func precedes(restricted map[int][]int, pages []int) map[int]map[int]bool {
+	dependents := make(map[int][]int)
+	for page, prerequisites := range restricted {
+		for _, prerequisite := range prerequisites {
+			dependents[prerequisite] = append(dependents[prerequisite], page)
+		}
+	}
+
+	reach := make(map[int]map[int]bool, len(pages))
+	for _, page := range pages {
+		reach[page] = make(map[int]bool)
+		stack := []int{page}
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, dependent := range dependents[current] {
+				if !reach[page][dependent] {
+					reach[page][dependent] = true
+					stack = append(stack, dependent)
+				}
+			}
+		}
+	}
+	return reach
+}
+
+// largestOrderedSubset returns, for each position, if its page is in a largest set of pages that can keep their
+// order in a fixed update. Such a set is one with no pair listed in the opposite order the rules require, directly
+// or through other pages. Pairs listed that way form a partial order, so the largest set is a largest antichain,
+// found from a maximum matching by Dilworth's and König's theorems. The rules between the pages must not form a cycle.
+func (g *RuleGraph) largestOrderedSubset(pages []int) []bool {
+	reach := precedes(g.Restrict(pages), pages)
+
+	// inverted[i] lists the later positions j whose page must come before the page at position i
+	inverted := make([][]int, len(pages))
+	for i := range pages {
+		for j := i + 1; j < len(pages); j++ {
+			if reach[pages[j]][pages[i]] {
+				inverted[i] = append(inverted[i], j)
+			}
+		}
+	}
+
+	// Maximum bipartite matching between earlier and later positions of inverted pairs
+	matchOfLater := make([]int, len(pages))
+	matchOfEarlier := make([]int, len(pages))
+	for i := range pages {
+		matchOfLater[i], matchOfEarlier[i] = -1, -1
+	}
+
+	var augment func(i int, visited []bool) bool
+	augment = func(i int, visited []bool) bool {
+		for _, j := range inverted[i] {
+			if visited[j] {
+				continue
+			}
+			visited[j] = true
+			if matchOfLater[j] == -1 || augment(matchOfLater[j], visited) {
+				matchOfLater[j], matchOfEarlier[i] = i, j
+				return true
+			}
+		}
+		return false
+	}
+	for i := range pages {
+		augment(i, make([]bool, len(pages)))
+	}
+
+	// Follow alternating paths from unmatched earlier positions. Positions reached as earlier but not as later
+	// are outside the minimum vertex cover on both sides, so they form a largest antichain.
+	reachedEarlier := make([]bool, len(pages))
+	reachedLater := make([]bool, len(pages))
+	var queue []int
+	for i := range pages {
+		if matchOfEarlier[i] == -1 {
+			reachedEarlier[i] = true
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, j := range inverted[i] {
+			if reachedLater[j] {
+				continue
+			}
+			reachedLater[j] = true
+			if k := matchOfLater[j]; k != -1 && !reachedEarlier[k] {
+				reachedEarlier[k] = true
+				queue = append(queue, k)
+			}
+		}
+	}
+
+	stays := make([]bool, len(pages))
+	for i := range pages {
+		stays[i] = reachedEarlier[i] && !reachedLater[i]
+	}
+	return stays
+}
+
+// WriteExplanations writes the explanations as text or as a JSON array.
+func WriteExplanations(w io.Writer, explanations []Explanation, format string) error {
+	switch format {
+	case explainJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(explanations)
+	case explainText:
+	default:
+		return fmt.Errorf("unknown explanation format %q", format)
+	}
+
+	out := bufio.NewWriter(w)
+	for _, explanation := range explanations {
+		fmt.Fprintf(out, "line %d: %s\n", explanation.Line, joinPages(explanation.Pages))
+		if explanation.Ordered {
+			fmt.Fprintln(out, "  in order")
+			continue
+		}
+
+		for _, violation := range explanation.Violations {
+			fmt.Fprintf(out, "  breaks %s: %d is at position %d, after %d at position %d\n", violation.Rule,
+				violation.Before, violation.BeforePosition, violation.After, violation.AfterPosition)
+		}
+
+		if explanation.Error != "" {
+			fmt.Fprintf(out, "  can not be fixed: %s\n", explanation.Error)
+			continue
+		}
+
+		fmt.Fprintf(out, "  must move: %s\n", joinPages(explanation.MustMove))
+		fmt.Fprintf(out, "  before:    %s\n", joinPages(explanation.Pages))
+		fmt.Fprintf(out, "  after:     %s\n", joinPages(explanation.Fixed))
+	}
+
+	return out.Flush()
+}
+
+// joinPages writes pages the way updates are written in the input.
+func joinPages(pages []int) string {
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		texts[i] = strconv.Itoa(page)
+	}
+	return strings.Join(texts, ",")
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Formats the rule graph can be exported in.
+const (
+	exportDOT     = "dot"
+	exportMermaid = "mermaid"
+)
+
+// ruleEdge is an edge of the exported graph, from the page printed first to the page printed after it.
+type ruleEdge struct {
+	before   int
+	after    int
+	violated bool
+	cycle    bool
+}
+
+// Export writes the rule graph as a Graphviz DOT or Mermaid flowchart, with an edge from each page to the pages
+// that must be printed after it. If an update is given, only the rules between its pages are written, each page
+// is labelled with its position, and the rules the update breaks are highlighted. Rules on a cycle are highlighted
+// too, along with their pages.
+func (g *RuleGraph) Export(w io.Writer, format string, update *Update) error {
+	if format != exportDOT && format != exportMermaid {
+		return fmt.Errorf("unknown export format %q", format)
+	}
+
+	prerequisites := g.prerequisites
+	var pages []int
+	if update != nil {
+		prerequisites = g.Restrict(update.Pages)
+		pages = append(pages, update.Pages...)
+	} else {
+		for page, before := range prerequisites {
+			pages = append(pages, page)
+			pages = append(pages, before...)
+		}
+		slices.Sort(pages)
+		pages = slices.Compact(pages)
+	}
+
+	// A rule is on a cycle if both its pages are in the same strongly connected component
+	component := make(map[int]int)
+	for index, pagesOfComponent := range stronglyConnected(pages, prerequisites) {
+		for _, page := range pagesOfComponent {
+			component[page] = index
+		}
+	}
+
+	violated := make(map[[2]int]bool)
+	positions := make(map[int]int)
+	if update != nil {
+		for _, violation := range g.Violations(update.Pages) {
+			violated[[2]int{violation.Before, violation.After}] = true
+		}
+		for i, page := range update.Pages {
+			positions[page] = i + 1
+		}
+	}
+
+	var edges []ruleEdge
+	onCycle := make(map[int]bool)
+	for _, after := range pages {
+		before := slices.Clone(prerequisites[after])
+		slices.Sort(before)
+		for _, page := range before {
+			edge := ruleEdge{
+				before:   page,
+				after:    after,
+				violated: violated[[2]int{page, after}],
+				cycle:    component[page] == component[after],
+			}
+			if edge.cycle {
+				onCycle[page], onCycle[after] = true, true
+			}
+			edges = append(edges, edge)
+		}
+	}
+
+	label := func(page int) string {
+		if position, ok := positions[page]; ok {
+			return fmt.Sprintf("%d (#%d)", page, position)
+		}
+		return strconv.Itoa(page)
+	}
+
+	out := bufio.NewWriter(w)
+	if format == exportDOT {
+		fmt.Fprintln(out, "digraph rules {")
+		fmt.Fprintln(out, "  rankdir=LR;")
+		fmt.Fprintln(out, "  node [shape=circle];")
+		for _, page := range pages {
+			style := ""
+			if onCycle[page] {
+				style = `, style=filled, fillcolor="#ffd8a8"`
+			}
+			fmt.Fprintf(out, "  %d [label=%q%s];\n", page, label(page), style)
+		}
+		for _, edge := range edges {
+			// A violated rule on a cycle is drawn as violated, but bold
+			var attributes []string
+			if edge.violated {
+				attributes = append(attributes, "color=red", "penwidth=2", `label="violated"`)
+			} else if edge.cycle {
+				attributes = append(attributes, `color="#e8590c"`)
+			}
+			if edge.cycle {
+				attributes = append(attributes, "style=bold")
+			}
+
+			fmt.Fprintf(out, "  %d -> %d", edge.before, edge.after)
+			if len(attributes) > 0 {
+				fmt.Fprintf(out, " [%s]", strings.Join(attributes, ", "))
+			}
+			fmt.Fprintln(out, ";")
+		}
+		fmt.Fprintln(out, "}")
+		return out.Flush()
+	}
+
+	fmt.Fprintln(out, "flowchart LR")
+	for _, page := range pages {
+		fmt.Fprintf(out, "  p%d((%q))\n", page, label(page))
+	}
+	for index, edge := range edges {
+		arrow := "-->"
+		if edge.violated {
+			arrow = "-- violated -->"
+		}
+		fmt.Fprintf(out, "  p%d %s p%d\n", edge.before, arrow, edge.after)
+
+		// Later styles win, so a violated rule on a cycle is drawn as violated
+		if edge.cycle {
+			fmt.Fprintf(out, "  linkStyle %d stroke:#e8590c,stroke-width:2px\n", index)
+		}
+		if edge.violated {
+			fmt.Fprintf(out, "  linkStyle %d stroke:red,stroke-width:3px\n", index)
+		}
+	}
+
+	var cyclePages []string
+	for _, page := range pages {
+		if onCycle[page] {
+			cyclePages = append(cyclePages, "p"+strconv.Itoa(page))
+		}
+	}
+	if len(cyclePages) > 0 {
+		fmt.Fprintln(out, "  classDef cycle fill:#ffd8a8")
+		fmt.Fprintf(out, "  class %s cycle\n", strings.Join(cyclePages, ","))
+	}
+
+	return out.Flush()
+}
+
+// stronglyConnected returns the strongly connected components of the graph with Tarjan's algorithm. Pages in the
+// same component are on a cycle together, unless the component has a single page.
+func stronglyConnected(pages []int, prerequisites map[int][]int) [][]int {
+	index := make(map[int]int)
+	lowLink := make(map[int]int)
+	onStack := make(map[int]bool)
+	var stack []int
+	var components [][]int
+
+	var visit func(page int)
+	visit = func(page int) {
+		index[page] = len(index)
+		lowLink[page] = index[page]
+		stack = append(stack, page)
+		onStack[page] = true
+
+		for _, prerequisite := range prerequisites[page] {
+			if _, ok := index[prerequisite]; !ok {
+				visit(prerequisite)
+				lowLink[page] = min(lowLink[page], lowLink[prerequisite])
+			} else if onStack[prerequisite] {
+				lowLink[page] = min(lowLink[page], index[prerequisite])
+			}
+		}
+
+		if lowLink[page] == index[page] {
+			var component []int
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == page {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, page := range pages {
+		if _, ok := index[page]; !ok {
+			visit(page)
+		}
+	}
+
+	return components
+}
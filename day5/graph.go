@@ -0,0 +1,151 @@
+package main
+
+import (
+	"container/heap"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RuleGraph holds the page ordering rules as a graph, with an edge from each page to the pages it requires.
+type RuleGraph struct {
+	// prerequisites maps a page to the pages that must be printed before it, if they are in the same update
+	prerequisites map[int][]int
+}
+
+// NewRuleGraph returns a graph of the rules, given as a map of each page to the pages that must be printed before it.
+func NewRuleGraph(prerequisites map[int][]int) *RuleGraph {
+	return &RuleGraph{prerequisites: prerequisites}
+}
+
+// CycleError is returned when the rules for the pages of an update form a cycle, so they can not all be followed.
+type CycleError struct {
+	// Pages are the pages of the cycle, each to be printed before the one after it, and the last before the first
+	Pages []int
+}
+
+func (e *CycleError) Error() string {
+	pages := make([]string, len(e.Pages)+1)
+	for i, page := range e.Pages {
+		pages[i] = strconv.Itoa(page)
+	}
+	pages[len(e.Pages)] = pages[0]
+	return "page ordering rules form a cycle: " + strings.Join(pages, " before ")
+}
+
+// Restrict returns the rules between the given pages, as a map of each page to the pages that must be printed
+// before it. Rules involving any other page do not apply to an update of these pages.
+func (g *RuleGraph) Restrict(pages []int) map[int][]int {
+	present := make(map[int]bool, len(pages))
+	for _, page := range pages {
+		present[page] = true
+	}
+
+	restricted := make(map[int][]int)
+	for _, page := range pages {
+		for _, prerequisite := range g.prerequisites[page] {
+			if present[prerequisite] && !slices.Contains(restricted[page], prerequisite) {
+				restricted[page] = append(restricted[page], prerequisite)
+			}
+		}
+	}
+	return restricted
+}
+
+// Sort returns the pages ordered to follow the rules between them. Among the pages free to go next, the one
+// listed first goes first, so an update that already follows the rules is returned unchanged.
+// If the rules between the pages form a cycle, a *CycleError is returned.
+func (g *RuleGraph) Sort(pages []int) ([]int, error) {
+	restricted := g.Restrict(pages)
+
+	position := make(map[int]int, len(pages))
+	for i, page := range pages {
+		position[page] = i
+	}
+
+	// Count the prerequisites left for each page, and list the pages waiting on each one
+	waiting := make(map[int]int, len(pages))
+	dependents := make(map[int][]int)
+	for page, prerequisites := range restricted {
+		waiting[page] = len(prerequisites)
+		for _, prerequisite := range prerequisites {
+			dependents[prerequisite] = append(dependents[prerequisite], page)
+		}
+	}
+
+	// Pages free to go next, by their position in the update
+	ready := &positionHeap{}
+	for i, page := range pages {
+		if waiting[page] == 0 {
+			heap.Push(ready, i)
+		}
+	}
+
+	sorted := make([]int, 0, len(pages))
+	for ready.Len() > 0 {
+		page := pages[heap.Pop(ready).(int)]
+		sorted = append(sorted, page)
+
+		for _, dependent := range dependents[page] {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				heap.Push(ready, position[dependent])
+			}
+		}
+	}
+
+	if len(sorted) < len(pages) {
+		return nil, &CycleError{Pages: findCycle(restricted, waiting)}
+	}
+
+	return sorted, nil
+}
+
+// findCycle returns a cycle among the pages still waiting on a prerequisite after a topological sort.
+// Each of them waits on another one, so following prerequisites from any of them must come back around.
+func findCycle(restricted map[int][]int, waiting map[int]int) []int {
+	// Start from the smallest waiting page, so the same cycle is reported every time
+	start := -1
+	for page, count := range waiting {
+		if count > 0 && (start == -1 || page < start) {
+			start = page
+		}
+	}
+
+	var path []int
+	visited := make(map[int]int)
+	page := start
+	for {
+		if index, ok := visited[page]; ok {
+			cycle := path[index:]
+			// The path follows prerequisites backwards, so reverse it to list each page before the one requiring it
+			slices.Reverse(cycle)
+			return cycle
+		}
+
+		visited[page] = len(path)
+		path = append(path, page)
+
+		for _, prerequisite := range restricted[page] {
+			if waiting[prerequisite] > 0 {
+				page = prerequisite
+				break
+			}
+		}
+	}
+}
+
+// positionHeap is a min-heap of positions in an update.
+type positionHeap []int
+
+func (h positionHeap) Len() int           { return len(h) }
+func (h positionHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h positionHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *positionHeap) Push(x any)        { *h = append(*h, x.(int)) }
+
+func (h *positionHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
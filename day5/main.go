@@ -1,13 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"log/slog"
+	"net/http"
 	"os"
 	"slices"
-	"strconv"
-	"strings"
 )
 
 func main() {
@@ -15,8 +13,30 @@ func main() {
 
 	// Get input file name
 	inputFlag := flag.String("input", "input/test_input.txt", "A file containing puzzle inputs.")
+	explainFlag := flag.String("explain", "", "Explain why each update is out of order and how to fix it, as text or json.")
+	explainOutputFlag := flag.String("explain-output", "", "A file to write the explanations to. Otherwise, they are written to stdout.")
+	exportFlag := flag.String("export", "", "Export the page ordering rules as a graph, in dot or mermaid.")
+	exportLineFlag := flag.Int("export-line", 0, "Only export the rules between the pages of the update on this line, highlighting the rules it breaks.")
+	exportOutputFlag := flag.String("export-output", "", "A file to write the graph to. Otherwise, it is written to stdout.")
+	countOrdersFlag := flag.Bool("count-orders", false, "Count the valid orders of the pages of each update.")
+	countSamplesFlag := flag.Int("count-samples", 10000, "The number of random orders used to estimate the count for updates of more than 20 pages.")
+	enumerateLineFlag := flag.Int("enumerate-line", 0, "List the valid orders of the pages of the update on this line.")
+	enumerateLimitFlag := flag.Int("enumerate-limit", 10, "The most valid orders to list, or 0 for all of them.")
+	repairFlag := flag.String("repair", repairSort, "How to fix out of order updates: sort, or minimal-moves to move as few pages as possible and log the moves.")
+	serveFlag := flag.String("serve", "", "Serve a JSON API to check and fix updates on this address, such as localhost:8080.")
+	replFlag := flag.Bool("repl", false, "Read commands to check and fix updates from stdin.")
 	flag.Parse()
 
+	// Without an output file, the explanations or the exported graph go to stdout, so the logs go to stderr
+	if (*explainFlag != "" && *explainOutputFlag == "") || (*exportFlag != "" && *exportOutputFlag == "") {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+
+	// The REPL answers on stdout, and the server may log from another goroutine, so the logs go to stderr
+	if *serveFlag != "" || *replFlag {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+
 	inputFileName := *inputFlag
 	logger = logger.With(
 		slog.String("inputFileName", inputFileName),
@@ -30,52 +50,115 @@ func main() {
 	}
 	defer file.Close()
 
-	pageOrderingRules := make(map[int][]int)
-	var pageNumLists [][]int
+	// Read the rules, then the updates
+	queue, err := ParsePrintQueue(file)
+	if err != nil {
+		// Report every malformed line on its own
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, lineErr := range joined.Unwrap() {
+				logger.Error("failed to parse input", "error", lineErr)
+			}
+		} else {
+			logger.Error("failed to parse input", "error", err)
+		}
+		os.Exit(1)
+	}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
+	pageOrderingRules := queue.Prerequisites()
+	graph := NewRuleGraph(pageOrderingRules)
 
-		tmpLogger := logger.With(
-			slog.String("line read", line),
-		)
+	// Explain each update
+	if *explainFlag != "" {
+		explanations := make([]Explanation, len(queue.Updates))
+		for i, update := range queue.Updates {
+			explanations[i] = graph.Explain(update)
+		}
 
-		// If line can be split by |, then it is a page ordering rule.
-		subStrings := strings.Split(line, "|")
-		if len(subStrings) == 2 {
-			num1, err := strconv.Atoi(subStrings[0])
+		output := os.Stdout
+		if *explainOutputFlag != "" {
+			output, err = os.Create(*explainOutputFlag)
 			if err != nil {
-				tmpLogger.Error("failed to convert num1 from string to int", "error", err, "num1 string", subStrings[0])
+				logger.Error("failed to create explanation file", "error", err, "explanation file", *explainOutputFlag)
+				os.Exit(1)
+			}
+			defer output.Close()
+		}
+
+		if err := WriteExplanations(output, explanations, *explainFlag); err != nil {
+			logger.Error("failed to write explanations", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Export the rule graph
+	if *exportFlag != "" {
+		var exportUpdate *Update
+		if *exportLineFlag != 0 {
+			for i, update := range queue.Updates {
+				if update.Line == *exportLineFlag {
+					exportUpdate = &queue.Updates[i]
+				}
+			}
+
+			if exportUpdate == nil {
+				logger.Error("no update on export line", "export line", *exportLineFlag)
 				os.Exit(1)
 			}
+		}
 
-			num2, err := strconv.Atoi(subStrings[1])
+		output := os.Stdout
+		if *exportOutputFlag != "" {
+			output, err = os.Create(*exportOutputFlag)
 			if err != nil {
-				tmpLogger.Error("failed to convert num2 from string to int", "error", err, "num2 string", subStrings[1])
+				logger.Error("failed to create export file", "error", err, "export file", *exportOutputFlag)
 				os.Exit(1)
 			}
+			defer output.Close()
+		}
 
-			// num2 requires num1 to be listed before if both are listed
-			pageOrderingRules[num2] = append(pageOrderingRules[num2], num1)
+		if err := graph.Export(output, *exportFlag, exportUpdate); err != nil {
+			logger.Error("failed to export rule graph", "error", err)
+			os.Exit(1)
 		}
+	}
 
-		// If line can be split by , then it is a list of page numbers.
-		nums := strings.Split(line, ",")
-		if len(nums) > 1 {
-			pageNumList := make([]int, len(nums))
-			for index, num := range nums {
-				pageNum, err := strconv.Atoi(num)
-				if err != nil {
-					tmpLogger.Error("failed to convert page number from string to int", "error", err, "page number string", num)
-					os.Exit(1)
-				}
+	// Count the valid orders of each update
+	if *countOrdersFlag {
+		for _, update := range queue.Updates {
+			count, err := graph.CountOrders(update.Pages, *countSamplesFlag, 1)
+			if err != nil {
+				logger.Error("failed to count valid orders", "error", err, "line", update.Line)
+				continue
+			}
 
-				pageNumList[index] = pageNum
+			logger.Info("valid orders counted", "line", update.Line, "count", count)
+		}
+	}
+
+	// List the valid orders of one update
+	if *enumerateLineFlag != 0 {
+		var enumerateUpdate *Update
+		for i, update := range queue.Updates {
+			if update.Line == *enumerateLineFlag {
+				enumerateUpdate = &queue.Updates[i]
 			}
+		}
+
+		if enumerateUpdate == nil {
+			logger.Error("no update on enumerate line", "enumerate line", *enumerateLineFlag)
+			os.Exit(1)
+		}
 
-			pageNumLists = append(pageNumLists, pageNumList)
+		listed, err := graph.EnumerateOrders(enumerateUpdate.Pages, *enumerateLimitFlag, func(order []int) bool {
+			logger.Info("valid order found", "line", enumerateUpdate.Line, "order", joinPages(order))
+			return true
+		})
+		if err != nil {
+			logger.Error("failed to list valid orders", "error", err, "line", enumerateUpdate.Line)
+			os.Exit(1)
 		}
+
+		logger.Info("valid orders are listed!", "line", enumerateUpdate.Line, "listed count", listed)
 	}
 
 	// Part 1
@@ -84,25 +167,25 @@ func main() {
 	var fixedMiddlePageSum int
 
 	// For each list of page numbers
-	for _, pageNumList := range pageNumLists {
-		correct, index, preReq := checkPageNumList(pageNumList, pageOrderingRules)
+	for _, update := range queue.Updates {
+		pageNumList := update.Pages
+		correct, _, _ := checkPageNumList(pageNumList, pageOrderingRules)
 
 		if correct {
 			middlePageSum += pageNumList[len(pageNumList)/2]
 		} else {
-			for {
-				// To fix this list, first remove the pre-requisite page number from the list
-				pageNumList = removeFromList(pageNumList, preReq)
-				// Then add it right before the page number that requires it
-				pageNumList = insertToList(pageNumList, preReq, index)
-
-				correct, index, preReq = checkPageNumList(pageNumList, pageOrderingRules)
-				if correct {
-					fixedMiddlePageSum += pageNumList[len(pageNumList)/2]
-					break
-				}
+			// To fix this list, reorder it by the rules between its pages
+			repair, err := graph.Repair(pageNumList, *repairFlag)
+			if err != nil {
+				logger.Error("failed to fix page number list", "error", err, "line", update.Line, "page number list", pageNumList)
+				os.Exit(1)
+			}
 
+			if *repairFlag == repairMinimalMoves {
+				logger.Info("page number list fixed", "line", update.Line, "fixed page number list", joinPages(repair.Fixed), "moves", repair.Moves)
 			}
+
+			fixedMiddlePageSum += repair.Fixed[len(repair.Fixed)/2]
 		}
 	}
 
@@ -111,6 +194,35 @@ func main() {
 
 	// Part 2 Solution
 	logger.Info("result #2 is ready!", "fixed middle page sum", fixedMiddlePageSum)
+
+	// Answer questions about other updates with the same rules
+	if *serveFlag != "" || *replFlag {
+		server := NewServer(graph, *repairFlag)
+
+		if *serveFlag != "" {
+			serve := func() {
+				logger.Info("serving!", "address", *serveFlag)
+				if err := http.ListenAndServe(*serveFlag, server.Handler()); err != nil {
+					logger.Error("failed to serve", "error", err)
+					os.Exit(1)
+				}
+			}
+
+			// Serve in the background while the REPL runs
+			if *replFlag {
+				go serve()
+			} else {
+				serve()
+			}
+		}
+
+		if *replFlag {
+			if err := server.RunREPL(os.Stdin, os.Stdout); err != nil {
+				logger.Error("failed to run REPL", "error", err)
+				os.Exit(1)
+			}
+		}
+	}
 }
 
 // checkPageNumList return if the list of page numbers is correct based on the ordering rules.
@@ -132,18 +244,3 @@ func checkPageNumList(pageNumList []int, pageOrderingRules map[int][]int) (bool,
 
 	return true, 0, 0
 }
-
-// removeFromList removes an element from a slice.
-func removeFromList[T comparable](l []T, item T) []T {
-	for i, other := range l {
-		if other == item {
-			return append(l[:i], l[i+1:]...)
-		}
-	}
-	return l
-}
-
-// insertToList inserts an element into a slice at the specified index.
-func insertToList[T any](array []T, value T, index int) []T {
-	return append(array[:index], append([]T{value}, array[index:]...)...)
-}
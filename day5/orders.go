@@ -0,0 +1,238 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// maxExactCountPages is the most pages whose valid orders are counted exactly. The count is at most 20!,
+// which fits in a uint64, and the table of subsets takes 8 MiB.
+const maxExactCountPages = 20
+
+// OrderCount is the number of valid orders of the pages of an update, exact for small updates and estimated
+// for large ones.
+type OrderCount struct {
+	Pages int  `json:"pages"`
+	Exact bool `json:"exact"`
+	// Count is set if the count is exact.
+	Count uint64 `json:"count,omitempty"`
+	// Estimate is the count if it is exact, or its estimate otherwise, with the standard error of the estimate.
+	Estimate float64 `json:"estimate"`
+	StdErr   float64 `json:"stdErr"`
+	// Unique is true if there is a single valid order.
+	Unique bool `json:"unique"`
+}
+
+// predecessorMasks returns, for each position, a bit mask of the positions of the pages that must come before it.
+func (g *RuleGraph) predecessorMasks(pages []int) []uint64 {
+	position := make(map[int]int, len(pages))
+	for i, page := range pages {
+		position[page] = i
+	}
+
+	masks := make([]uint64, len(pages))
+	for page, prerequisites := range g.Restrict(pages) {
+		for _, prerequisite := range prerequisites {
+			masks[position[page]] |= 1 << position[prerequisite]
+		}
+	}
+	return masks
+}
+
+// CountOrders counts the valid orders of the pages, which are the linear extensions of the rules between them.
+// Up to 20 pages, they are counted exactly with a table of the number of valid orders of each subset of pages that
+// can go first. For more pages, the count is estimated from random valid orders, each built by picking uniformly
+// among the pages free to go next and weighted by the product of the number of choices, which is an unbiased
+// estimate of the count. If the rules between the pages form a cycle, a *CycleError is returned.
+func (g *RuleGraph) CountOrders(pages []int, samples int, seed int64) (OrderCount, error) {
+	if _, err := g.Sort(pages); err != nil {
+		return OrderCount{}, err
+	}
+
+	count := OrderCount{Pages: len(pages), Unique: g.UniqueOrder(pages)}
+	if count.Unique {
+		count.Exact, count.Count, count.Estimate = true, 1, 1
+		return count, nil
+	}
+
+	if len(pages) <= maxExactCountPages {
+		count.Exact = true
+		count.Count = g.countOrdersExactly(pages)
+		count.Estimate = float64(count.Count)
+		return count, nil
+	}
+
+	count.Estimate, count.StdErr = g.estimateOrders(pages, max(samples, 2), rand.New(rand.NewSource(seed)))
+	return count, nil
+}
+
+// countOrdersExactly counts the valid orders of at most 20 pages with a table over subsets of pages.
+func (g *RuleGraph) countOrdersExactly(pages []int) uint64 {
+	masks := g.predecessorMasks(pages)
+
+	// ways[subset] is the number of valid orders of the subset, when it is printed first
+	ways := make([]uint64, 1<<len(pages))
+	ways[0] = 1
+	for subset := range ways {
+		if ways[subset] == 0 {
+			continue
+		}
+		for i, mask := range masks {
+			if subset&(1<<i) == 0 && mask&^uint64(subset) == 0 {
+				ways[subset|1<<i] += ways[subset]
+			}
+		}
+	}
+	return ways[len(ways)-1]
+}
+
+// estimateOrders returns an estimate of the number of valid orders of the pages from random valid orders, along
+// with its standard error.
+func (g *RuleGraph) estimateOrders(pages []int, samples int, rng *rand.Rand) (float64, float64) {
+	prerequisites := g.Restrict(pages)
+	dependents := make(map[int][]int)
+	for page, before := range prerequisites {
+		for _, prerequisite := range before {
+			dependents[prerequisite] = append(dependents[prerequisite], page)
+		}
+	}
+
+	var sum, sumOfSquares float64
+	for sample := 0; sample < samples; sample++ {
+		waiting := make(map[int]int, len(pages))
+		var ready []int
+		for _, page := range pages {
+			waiting[page] = len(prerequisites[page])
+			if waiting[page] == 0 {
+				ready = append(ready, page)
+			}
+		}
+
+		weight := 1.0
+		for len(ready) > 0 {
+			weight *= float64(len(ready))
+
+			i := rng.Intn(len(ready))
+			page := ready[i]
+			ready[i] = ready[len(ready)-1]
+			ready = ready[:len(ready)-1]
+
+			for _, dependent := range dependents[page] {
+				waiting[dependent]--
+				if waiting[dependent] == 0 {
+					ready = append(ready, dependent)
+				}
+			}
+		}
+
+		sum += weight
+		sumOfSquares += weight * weight
+	}
+
+	mean := sum / float64(samples)
+	variance := (sumOfSquares - sum*mean) / float64(samples-1)
+	return mean, math.Sqrt(max(variance, 0) / float64(samples))
+}
+
+// UniqueOrder returns if the pages have a single valid order, which is when every step of a topological sort has
+// a single page free to go next. It returns false if the rules between the pages form a cycle.
+func (g *RuleGraph) UniqueOrder(pages []int) bool {
+	prerequisites := g.Restrict(pages)
+	dependents := make(map[int][]int)
+	waiting := make(map[int]int, len(pages))
+	for page, before := range prerequisites {
+		waiting[page] = len(before)
+		for _, prerequisite := range before {
+			dependents[prerequisite] = append(dependents[prerequisite], page)
+		}
+	}
+
+	var ready []int
+	for _, page := range pages {
+		if waiting[page] == 0 {
+			ready = append(ready, page)
+		}
+	}
+
+	for placed := 0; placed < len(pages); placed++ {
+		if len(ready) != 1 {
+			return false
+		}
+
+		page := ready[0]
+		ready = ready[:0]
+		for _, dependent := range dependents[page] {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	return true
+}
+
+// EnumerateOrders calls visit with each valid order of the pages, in lexicographic order of the positions of the
+// pages in the update, until visit returns false or limit orders have been visited. A limit of zero or less
+// means no limit. The orders are generated one at a time by backtracking, so stopping early does not build the
+// rest. The slice passed to visit is reused, so it must be copied to be kept. It returns the number of orders
+// visited, or a *CycleError if the rules between the pages form a cycle.
+func (g *RuleGraph) EnumerateOrders(pages []int, limit int, visit func(order []int) bool) (int, error) {
+	if _, err := g.Sort(pages); err != nil {
+		return 0, err
+	}
+
+	position := make(map[int]int, len(pages))
+	for i, page := range pages {
+		position[page] = i
+	}
+
+	// Count the prerequisites left for each position, and list the positions waiting on each one
+	waiting := make([]int, len(pages))
+	dependents := make([][]int, len(pages))
+	for page, prerequisites := range g.Restrict(pages) {
+		waiting[position[page]] = len(prerequisites)
+		for _, prerequisite := range prerequisites {
+			dependents[position[prerequisite]] = append(dependents[position[prerequisite]], position[page])
+		}
+	}
+
+	placed := make([]bool, len(pages))
+	order := make([]int, 0, len(pages))
+	visited := 0
+
+	var extend func() bool
+	extend = func() bool {
+		if len(order) == len(pages) {
+			visited++
+			return visit(order) && (limit <= 0 || visited < limit)
+		}
+
+		for i := range pages {
+			if placed[i] || waiting[i] > 0 {
+				continue
+			}
+
+			placed[i] = true
+			for _, dependent := range dependents[i] {
+				waiting[dependent]--
+			}
+			order = append(order, pages[i])
+
+			more := extend()
+
+			order = order[:len(order)-1]
+			for _, dependent := range dependents[i] {
+				waiting[dependent]++
+			}
+			placed[i] = false
+
+			if !more {
+				return false
+			}
+		}
+		return true
+	}
+
+	extend()
+	return visited, nil
+}
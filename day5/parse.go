@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Rule is a page ordering rule X|Y: if both pages are in an update, Before must be printed before After.
+type Rule struct {
+	Before int
+	After  int
+	// Line is the line number of the rule in the input
+	Line int
+}
+
+// Update is the list of pages of one update, in the order they are printed.
+type Update struct {
+	Pages []int
+	// Line is the line number of the update in the input
+	Line int
+}
+
+// PrintQueue is the parsed puzzle input: the page ordering rules, then the updates.
+type PrintQueue struct {
+	Rules   []Rule
+	Updates []Update
+}
+
+// LineError is a problem with one line of the input.
+type LineError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d %q: %v", e.Line, e.Text, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// ParsePrintQueue reads the rules, one X|Y per line, then a blank line, then the updates, one comma-separated list
+// of pages per line. An update can have a single page. Every malformed line is reported, along with duplicate
+// rules and pages repeated within an update, as *LineError values joined into one error.
+func ParsePrintQueue(r io.Reader) (PrintQueue, error) {
+	var queue PrintQueue
+	var errs []error
+	inUpdates := false
+	ruleLines := make(map[[2]int]int)
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		lineError := func(format string, args ...any) {
+			errs = append(errs, &LineError{Line: lineNumber, Text: line, Err: fmt.Errorf(format, args...)})
+		}
+
+		// The first blank line ends the rules, and later ones are ignored
+		if line == "" {
+			inUpdates = true
+			continue
+		}
+
+		if !inUpdates {
+			parts := strings.Split(line, "|")
+			if len(parts) != 2 {
+				if strings.Contains(line, ",") {
+					lineError("update found before the blank line ending the rules")
+				} else {
+					lineError("rule must be two pages separated by |")
+				}
+				continue
+			}
+
+			before, err := parsePage(parts[0])
+			if err != nil {
+				lineError("first page: %w", err)
+				continue
+			}
+			after, err := parsePage(parts[1])
+			if err != nil {
+				lineError("second page: %w", err)
+				continue
+			}
+
+			if before == after {
+				lineError("rule orders page %d before itself", before)
+				continue
+			}
+			if previous, ok := ruleLines[[2]int{before, after}]; ok {
+				lineError("duplicate of the rule on line %d", previous)
+				continue
+			}
+			ruleLines[[2]int{before, after}] = lineNumber
+
+			queue.Rules = append(queue.Rules, Rule{Before: before, After: after, Line: lineNumber})
+			continue
+		}
+
+		if strings.Contains(line, "|") {
+			lineError("rule found after the blank line ending the rules")
+			continue
+		}
+
+		update := Update{Line: lineNumber}
+		positions := make(map[int]int)
+		valid := true
+		for index, field := range strings.Split(line, ",") {
+			page, err := parsePage(field)
+			if err != nil {
+				lineError("page %d: %w", index+1, err)
+				valid = false
+				break
+			}
+
+			if previous, ok := positions[page]; ok {
+				lineError("page %d is listed at positions %d and %d", page, previous+1, index+1)
+				valid = false
+				break
+			}
+			positions[page] = index
+
+			update.Pages = append(update.Pages, page)
+		}
+
+		if valid {
+			queue.Updates = append(queue.Updates, update)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return queue, errors.Join(errs...)
+}
+
+// parsePage parses a page number, which must be a non-negative integer.
+func parsePage(text string) (int, error) {
+	page, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil {
+		return 0, fmt.Errorf("invalid page number %q", strings.TrimSpace(text))
+	}
+	if page < 0 {
+		return 0, fmt.Errorf("page number %d must not be negative", page)
+	}
+	return page, nil
+}
+
+// Prerequisites returns a map of each page to the pages that must be printed before it.
+func (q PrintQueue) Prerequisites() map[int][]int {
+	prerequisites := make(map[int][]int)
+	for _, rule := range q.Rules {
+		prerequisites[rule.After] = append(prerequisites[rule.After], rule.Before)
+	}
+	return prerequisites
+}
@@ -0,0 +1,126 @@
+package main
+
+import "fmt"
+
+// Strategies to repair an out of order update.
+const (
+	repairSort         = "sort"
+	repairMinimalMoves = "minimal-moves"
+)
+
+// Move is a page moved to repair an update, with its positions before and after, starting from 1.
+type Move struct {
+	Page int `json:"page"`
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Repair is a valid order of the pages of an update, along with the pages moved to get it.
+type Repair struct {
+	Fixed []int  `json:"fixed"`
+	Moves []Move `json:"moves"`
+}
+
+// Repair returns a valid order of the pages with the given strategy. Sort is the topological sort of Sort, and
+// minimal-moves moves as few pages as possible. If the rules between the pages form a cycle, a *CycleError is returned.
+func (g *RuleGraph) Repair(pages []int, strategy string) (Repair, error) {
+	var fixed []int
+	var err error
+	switch strategy {
+	case repairSort:
+		fixed, err = g.Sort(pages)
+	case repairMinimalMoves:
+		fixed, err = g.minimalMoves(pages)
+	default:
+		return Repair{}, fmt.Errorf("unknown repair strategy %q", strategy)
+	}
+	if err != nil {
+		return Repair{}, err
+	}
+
+	return Repair{Fixed: fixed, Moves: moves(pages, fixed)}, nil
+}
+
+// minimalMoves returns a valid order of the pages in which a largest set of pages keeps its order, so the fewest
+// pages move. It sorts the pages with an extra rule between each page of the set and the next one, which can not
+// form a cycle since no two pages of the set are listed in the opposite order the rules require.
+func (g *RuleGraph) minimalMoves(pages []int) ([]int, error) {
+	// Check for a cycle first, since the largest set is only found without one
+	if _, err := g.Sort(pages); err != nil {
+		return nil, err
+	}
+
+	prerequisites := g.Restrict(pages)
+	previous := -1
+	for i, stays := range g.largestOrderedSubset(pages) {
+		if !stays {
+			continue
+		}
+		if previous != -1 {
+			prerequisites[pages[i]] = append(prerequisites[pages[i]], pages[previous])
+		}
+		previous = i
+	}
+
+	return NewRuleGraph(prerequisites).Sort(pages)
+}
+
+// moves returns the pages whose order relative to the other pages changed from before to after. The pages that
+// kept their order are found as a longest common subsequence, so the moves are as few as the orders allow.
+func moves(before []int, after []int) []Move {
+	position := make(map[int]int, len(after))
+	for i, page := range after {
+		position[page] = i
+	}
+
+	// The pages that keep their order are a longest increasing subsequence of their new positions
+	newPositions := make([]int, len(before))
+	for i, page := range before {
+		newPositions[i] = position[page]
+	}
+	keeps := longestIncreasing(newPositions)
+
+	moved := []Move{}
+	for i, page := range before {
+		if !keeps[i] {
+			moved = append(moved, Move{Page: page, From: i + 1, To: position[page] + 1})
+		}
+	}
+	return moved
+}
+
+// longestIncreasing returns, for each index, if its value is in a longest strictly increasing subsequence.
+func longestIncreasing(values []int) []bool {
+	// tails[k] is the index of the smallest last value of an increasing subsequence of length k+1
+	var tails []int
+	parent := make([]int, len(values))
+	for i, value := range values {
+		low, high := 0, len(tails)
+		for low < high {
+			middle := (low + high) / 2
+			if values[tails[middle]] < value {
+				low = middle + 1
+			} else {
+				high = middle
+			}
+		}
+
+		parent[i] = -1
+		if low > 0 {
+			parent[i] = tails[low-1]
+		}
+		if low == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[low] = i
+		}
+	}
+
+	in := make([]bool, len(values))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i != -1; i = parent[i] {
+			in[i] = true
+		}
+	}
+	return in
+}
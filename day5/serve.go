@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ReachabilityIndex holds the transitive closure of page ordering rules, so it can tell in constant time
+// whether one page must be printed before another, directly or through other pages.
+type ReachabilityIndex struct {
+	graph *RuleGraph
+	pages []int
+	index map[int]int
+	// before[i] is a bit set of the indices of the pages that must be printed before pages[i]
+	before [][]uint64
+}
+
+// NewReachabilityIndex builds the transitive closure of the rules with a search from every page, taking
+// O(V·(V+E)) time and V² bits. Pages on a cycle of the rules, as every page of the full puzzle rules is, must
+// all precede each other, so Cycle tells them apart from the pages truly before them.
+func NewReachabilityIndex(g *RuleGraph) *ReachabilityIndex {
+	r := &ReachabilityIndex{graph: g, index: make(map[int]int)}
+	add := func(page int) {
+		if _, ok := r.index[page]; !ok {
+			r.index[page] = len(r.pages)
+			r.pages = append(r.pages, page)
+		}
+	}
+	for page, prerequisites := range g.prerequisites {
+		add(page)
+		for _, prerequisite := range prerequisites {
+			add(prerequisite)
+		}
+	}
+
+	words := (len(r.pages) + 63) / 64
+	r.before = make([][]uint64, len(r.pages))
+	for i, page := range r.pages {
+		r.before[i] = make([]uint64, words)
+		stack := []int{page}
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, prerequisite := range g.prerequisites[current] {
+				j := r.index[prerequisite]
+				if r.before[i][j/64]&(1<<(j%64)) == 0 {
+					r.before[i][j/64] |= 1 << (j % 64)
+					stack = append(stack, prerequisite)
+				}
+			}
+		}
+	}
+
+	return r
+}
+
+// Within returns the index of the rules between the pages of an update, which are the only rules that apply
+// to it. Pages outside the update do not link the pages of the update, so the closure of every rule, cyclic for
+// the full puzzle rules, cannot answer questions about an update, and a new index is built for each one.
+func (r *ReachabilityIndex) Within(pages []int) *ReachabilityIndex {
+	return NewReachabilityIndex(NewRuleGraph(r.graph.Restrict(pages)))
+}
+
+// Precedes returns if the rules require page a to be printed before page b, directly or through other pages.
+func (r *ReachabilityIndex) Precedes(a int, b int) bool {
+	i, okA := r.index[a]
+	j, okB := r.index[b]
+	return okA && okB && r.before[j][i/64]&(1<<(i%64)) != 0
+}
+
+// Before returns the pages the rules require to be printed before the page, directly or through other pages,
+// in increasing order. A page on a cycle is listed before itself.
+func (r *ReachabilityIndex) Before(page int) []int {
+	pages := []int{}
+	j, ok := r.index[page]
+	if !ok {
+		return pages
+	}
+
+	for word, set := range r.before[j] {
+		for set != 0 {
+			bit := bits.TrailingZeros64(set)
+			pages = append(pages, r.pages[word*64+bit])
+			set &= set - 1
+		}
+	}
+	slices.Sort(pages)
+	return pages
+}
+
+// Cycle returns the pages on a cycle of the rules with the page, itself included, in increasing order. These are
+// the pages both before and after it. It returns nil if the page is on no cycle.
+func (r *ReachabilityIndex) Cycle(page int) []int {
+	if !r.Precedes(page, page) {
+		return nil
+	}
+
+	var cycle []int
+	for _, other := range r.Before(page) {
+		if r.Precedes(page, other) {
+			cycle = append(cycle, other)
+		}
+	}
+	return cycle
+}
+
+// CheckResult is the answer to whether an update is in order.
+type CheckResult struct {
+	Valid      bool            `json:"valid"`
+	Violations []RuleViolation `json:"violations"`
+}
+
+// PredecessorsResult lists the pages that must be printed before a page.
+type PredecessorsResult struct {
+	Page int `json:"page"`
+	// Update is the update the question is about, if any. Only the rules between its pages apply.
+	Update []int `json:"update,omitempty"`
+	// Direct are the pages with a rule putting them before the page.
+	Direct []int `json:"direct"`
+	// Transitive are the pages that must come before the page directly or through other pages.
+	Transitive []int `json:"transitive"`
+	// Cycle lists the pages on a cycle of the rules with the page, itself included. Each of them would have to
+	// come before every other, so Transitive is left empty rather than listing them all.
+	Cycle []int `json:"cycle,omitempty"`
+}
+
+// Server answers questions about updates with rules loaded once, over HTTP or in a REPL. The index of every rule
+// only answers which pages come before a page regardless of any update.
+type Server struct {
+	graph    *RuleGraph
+	index    *ReachabilityIndex
+	strategy string
+}
+
+// NewServer returns a server for the rules, fixing updates with the given repair strategy.
+func NewServer(graph *RuleGraph, strategy string) *Server {
+	return &Server{graph: graph, index: NewReachabilityIndex(graph), strategy: strategy}
+}
+
+// Check returns if the update is in order, with every rule it breaks. Only the rules between its pages apply, and
+// they are checked directly, as the index of every rule does not tell which of them apply to the update.
+func (s *Server) Check(pages []int) CheckResult {
+	violations := s.graph.Violations(pages)
+	return CheckResult{Valid: len(violations) == 0, Violations: violations}
+}
+
+// Fix returns the update in a valid order. An update that breaks no rule is returned as is, without a repair.
+func (s *Server) Fix(pages []int) (Repair, error) {
+	if len(s.graph.Violations(pages)) == 0 {
+		return Repair{Fixed: slices.Clone(pages), Moves: []Move{}}, nil
+	}
+	return s.graph.Repair(pages, s.strategy)
+}
+
+// Predecessors returns the pages that must be printed before the page. With an update, which must hold the page,
+// only the rules between its pages apply, as when it is printed. Without one, every rule applies. If the page is
+// on a cycle of the rules that apply, the pages of the cycle are returned instead of the pages before it.
+func (s *Server) Predecessors(page int, update []int) (PredecessorsResult, error) {
+	index, direct := s.index, slices.Clone(s.graph.prerequisites[page])
+	if update != nil {
+		if !slices.Contains(update, page) {
+			return PredecessorsResult{}, fmt.Errorf("page %d is not in the update", page)
+		}
+		index = s.index.Within(update)
+		direct = slices.Clone(index.graph.prerequisites[page])
+	}
+
+	if direct == nil {
+		direct = []int{}
+	}
+	slices.Sort(direct)
+
+	result := PredecessorsResult{Page: page, Update: update, Direct: direct, Cycle: index.Cycle(page)}
+	if result.Cycle != nil {
+		result.Transitive = []int{}
+	} else {
+		result.Transitive = index.Before(page)
+	}
+	return result, nil
+}
+
+// updateRequest is the body of the check and fix requests.
+type updateRequest struct {
+	Pages []int `json:"pages"`
+}
+
+// Handler returns the JSON over HTTP API:
+//
+//	POST /check {"pages": [75, 47, 61]} returns whether the update is in order, with the rules it breaks.
+//	POST /fix {"pages": [75, 97, 47]} returns the update in a valid order, with the pages moved.
+//	GET /predecessors?page=47 returns the pages that must be printed before page 47.
+//	GET /predecessors?page=47&update=75,47,61 only applies the rules between the pages of the update.
+//
+// Errors are returned as {"error": "..."}.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/check", func(w http.ResponseWriter, r *http.Request) {
+		pages, ok := readUpdate(w, r)
+		if ok {
+			writeJSON(w, http.StatusOK, s.Check(pages))
+		}
+	})
+
+	mux.HandleFunc("/fix", func(w http.ResponseWriter, r *http.Request) {
+		pages, ok := readUpdate(w, r)
+		if !ok {
+			return
+		}
+
+		repair, err := s.Fix(pages)
+		var cycleErr *CycleError
+		if errors.As(err, &cycleErr) {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		} else if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, repair)
+	})
+
+	mux.HandleFunc("/predecessors", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+			return
+		}
+
+		page, err := parsePage(r.URL.Query().Get("page"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var update []int
+		if r.URL.Query().Has("update") {
+			update, err = parseUpdateArgument(r.URL.Query().Get("update"))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		result, err := s.Predecessors(page, update)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+
+	return mux
+}
+
+// readUpdate reads the pages of a check or fix request, writing an error response if it is not valid.
+func readUpdate(w http.ResponseWriter, r *http.Request) ([]int, bool) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return nil, false
+	}
+
+	var request updateRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return nil, false
+	}
+
+	if err := validateUpdate(request.Pages); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	return request.Pages, true
+}
+
+// validateUpdate checks an update the same way ParsePrintQueue does.
+func validateUpdate(pages []int) error {
+	if len(pages) == 0 {
+		return errors.New("update has no pages")
+	}
+
+	positions := make(map[int]int, len(pages))
+	for index, page := range pages {
+		if page < 0 {
+			return fmt.Errorf("page number %d must not be negative", page)
+		}
+		if previous, ok := positions[page]; ok {
+			return fmt.Errorf("page %d is listed at positions %d and %d", page, previous+1, index+1)
+		}
+		positions[page] = index
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// replHelp lists the commands of the REPL.
+const replHelp = `commands:
+  check 75,47,61   tell if the update is in order, with the rules it breaks
+  fix 75,97,47     print the update in a valid order, with the pages moved
+  before 47        list the pages that must be printed before page 47
+  before 47 in 75,47,61
+                   the same, with only the rules between the pages of the update
+  help             print this help
+  quit             stop`
+
+// RunREPL reads one command per line from r and writes the answers to w, until quit or the end of r.
+func (s *Server) RunREPL(r io.Reader, w io.Writer) error {
+	out := bufio.NewWriter(w)
+	scanner := bufio.NewScanner(r)
+
+	fmt.Fprintln(out, replHelp)
+	for {
+		fmt.Fprint(out, "> ")
+		if err := out.Flush(); err != nil {
+			return err
+		}
+
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			return scanner.Err()
+		}
+
+		command, argument, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		argument = strings.TrimSpace(argument)
+
+		switch command {
+		case "":
+		case "check":
+			pages, err := parseUpdateArgument(argument)
+			if err != nil {
+				fmt.Fprintln(out, "error:", err)
+				continue
+			}
+
+			result := s.Check(pages)
+			if result.Valid {
+				fmt.Fprintln(out, "in order")
+			}
+			for _, violation := range result.Violations {
+				fmt.Fprintf(out, "breaks %s: %d is at position %d, after %d at position %d\n", violation.Rule,
+					violation.Before, violation.BeforePosition, violation.After, violation.AfterPosition)
+			}
+		case "fix":
+			pages, err := parseUpdateArgument(argument)
+			if err != nil {
+				fmt.Fprintln(out, "error:", err)
+				continue
+			}
+
+			repair, err := s.Fix(pages)
+			if err != nil {
+				fmt.Fprintln(out, "error:", err)
+				continue
+			}
+
+			fmt.Fprintln(out, joinPages(repair.Fixed))
+			for _, move := range repair.Moves {
+				fmt.Fprintf(out, "moved %d from position %d to %d\n", move.Page, move.From, move.To)
+			}
+		case "before":
+			pageArgument, updateArgument, inUpdate := strings.Cut(argument, " in ")
+			page, err := parsePage(pageArgument)
+			if err != nil {
+				fmt.Fprintln(out, "error:", err)
+				continue
+			}
+
+			var update []int
+			if inUpdate {
+				update, err = parseUpdateArgument(strings.TrimSpace(updateArgument))
+				if err != nil {
+					fmt.Fprintln(out, "error:", err)
+					continue
+				}
+			}
+
+			result, err := s.Predecessors(page, update)
+			if err != nil {
+				fmt.Fprintln(out, "error:", err)
+				continue
+			}
+
+			fmt.Fprintf(out, "direct: %s\n", joinPages(result.Direct))
+			if result.Cycle != nil {
+				fmt.Fprintf(out, "on a cycle with: %s\n", joinPages(result.Cycle))
+			} else {
+				fmt.Fprintf(out, "transitive: %s\n", joinPages(result.Transitive))
+			}
+		case "help":
+			fmt.Fprintln(out, replHelp)
+		case "quit", "exit":
+			return out.Flush()
+		default:
+			fmt.Fprintf(out, "error: unknown command %q, try help\n", command)
+		}
+	}
+}
+
+// parseUpdateArgument parses an update written as in the input.
+func parseUpdateArgument(argument string) ([]int, error) {
+	if argument == "" {
+		return nil, errors.New("update has no pages")
+	}
+
+	var pages []int
+	for index, field := range strings.Split(argument, ",") {
+		page, err := parsePage(field)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", index+1, err)
+		}
+		pages = append(pages, page)
+	}
+	return pages, validateUpdate(pages)
+}
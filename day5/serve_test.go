@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestServer returns a server for the rules of an input file, fixing updates with Sort.
+func newTestServer(t *testing.T, fileName string) *Server {
+	t.Helper()
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", fileName, err)
+	}
+	defer file.Close()
+
+	queue, err := ParsePrintQueue(file)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", fileName, err)
+	}
+	return NewServer(NewRuleGraph(queue.Prerequisites()), repairSort)
+}
+
+// cyclicServer returns a server for rules putting 3 before 1, 1 before 2 and 2 before 3.
+func cyclicServer() *Server {
+	return NewServer(NewRuleGraph(map[int][]int{1: {3}, 2: {1}, 3: {2}}), repairSort)
+}
+
+// request sends a request to the handler and decodes the JSON response into response.
+func request(t *testing.T, handler http.Handler, method string, target string, body string, response any) int {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("%s %s returned content type %q, want application/json", method, target, contentType)
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(response); err != nil {
+		t.Fatalf("%s %s returned invalid JSON: %v", method, target, err)
+	}
+	return recorder.Code
+}
+
+func TestCheck(t *testing.T) {
+	handler := newTestServer(t, "input/test_input.txt").Handler()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantValid  bool
+		wantRules  []string
+		wantStatus int
+	}{
+		{name: "in order", body: `{"pages": [75, 47, 61, 53, 29]}`, wantValid: true, wantRules: []string{}, wantStatus: http.StatusOK},
+		{name: "one rule broken", body: `{"pages": [75, 97, 47, 61, 53]}`, wantRules: []string{"97|75"}, wantStatus: http.StatusOK},
+		{name: "several rules broken", body: `{"pages": [97, 13, 75, 29, 47]}`, wantRules: []string{"29|13", "47|13", "75|13", "47|29"}, wantStatus: http.StatusOK},
+		{name: "single page", body: `{"pages": [42]}`, wantValid: true, wantRules: []string{}, wantStatus: http.StatusOK},
+		{name: "no pages", body: `{"pages": []}`, wantStatus: http.StatusBadRequest},
+		{name: "repeated page", body: `{"pages": [75, 47, 75]}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", body: `{"pages": [75], "line": 3}`, wantStatus: http.StatusBadRequest},
+		{name: "not json", body: `75,47,61`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var response struct {
+				CheckResult
+				Error string `json:"error"`
+			}
+			status := request(t, handler, http.MethodPost, "/check", test.body, &response)
+			if status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (error %q)", status, test.wantStatus, response.Error)
+			}
+			if status != http.StatusOK {
+				if response.Error == "" {
+					t.Error("error response has no error message")
+				}
+				return
+			}
+
+			if response.Valid != test.wantValid {
+				t.Errorf("valid = %t, want %t", response.Valid, test.wantValid)
+			}
+			rules := []string{}
+			for _, violation := range response.Violations {
+				rules = append(rules, violation.Rule)
+			}
+			if !reflect.DeepEqual(rules, test.wantRules) {
+				t.Errorf("broken rules = %v, want %v", rules, test.wantRules)
+			}
+		})
+	}
+
+	t.Run("wrong method", func(t *testing.T) {
+		var response map[string]string
+		if status := request(t, handler, http.MethodGet, "/check", "", &response); status != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want %d", status, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func TestFix(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     *Server
+		body       string
+		wantFixed  []int
+		wantMoves  int
+		wantStatus int
+	}{
+		{name: "out of order", server: newTestServer(t, "input/test_input.txt"), body: `{"pages": [97, 13, 75, 29, 47]}`, wantFixed: []int{97, 75, 47, 29, 13}, wantMoves: 2, wantStatus: http.StatusOK},
+		{name: "one page out of place", server: newTestServer(t, "input/test_input.txt"), body: `{"pages": [75, 97, 47, 61, 53]}`, wantFixed: []int{97, 75, 47, 61, 53}, wantMoves: 1, wantStatus: http.StatusOK},
+		{name: "in order", server: newTestServer(t, "input/test_input.txt"), body: `{"pages": [75, 47, 61, 53, 29]}`, wantFixed: []int{75, 47, 61, 53, 29}, wantMoves: 0, wantStatus: http.StatusOK},
+		{name: "cycle", server: cyclicServer(), body: `{"pages": [1, 2, 3]}`, wantStatus: http.StatusUnprocessableEntity},
+		{name: "cycle broken by a missing page", server: cyclicServer(), body: `{"pages": [2, 1]}`, wantFixed: []int{1, 2}, wantMoves: 1, wantStatus: http.StatusOK},
+		{name: "negative page", server: cyclicServer(), body: `{"pages": [1, -2]}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var response struct {
+				Repair
+				Error string `json:"error"`
+			}
+			status := request(t, test.server.Handler(), http.MethodPost, "/fix", test.body, &response)
+			if status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (error %q)", status, test.wantStatus, response.Error)
+			}
+			if status != http.StatusOK {
+				if response.Error == "" {
+					t.Error("error response has no error message")
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(response.Fixed, test.wantFixed) {
+				t.Errorf("fixed = %v, want %v", response.Fixed, test.wantFixed)
+			}
+			if len(response.Moves) != test.wantMoves {
+				t.Errorf("moves = %v, want %d moves", response.Moves, test.wantMoves)
+			}
+		})
+	}
+}
+
+func TestPredecessors(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     *Server
+		target     string
+		want       PredecessorsResult
+		wantStatus int
+	}{
+		{
+			name:       "every rule",
+			server:     newTestServer(t, "input/test_input.txt"),
+			target:     "/predecessors?page=61",
+			want:       PredecessorsResult{Page: 61, Direct: []int{47, 75, 97}, Transitive: []int{47, 75, 97}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "through other pages",
+			server:     NewServer(NewRuleGraph(map[int][]int{2: {1}, 3: {2}}), repairSort),
+			target:     "/predecessors?page=3",
+			want:       PredecessorsResult{Page: 3, Direct: []int{2}, Transitive: []int{1, 2}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "within an update",
+			server:     newTestServer(t, "input/test_input.txt"),
+			target:     "/predecessors?page=61&update=75,47,61",
+			want:       PredecessorsResult{Page: 61, Update: []int{75, 47, 61}, Direct: []int{47, 75}, Transitive: []int{47, 75}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "first page",
+			server:     newTestServer(t, "input/test_input.txt"),
+			target:     "/predecessors?page=97",
+			want:       PredecessorsResult{Page: 97, Direct: []int{}, Transitive: []int{}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "on a cycle",
+			server:     cyclicServer(),
+			target:     "/predecessors?page=1",
+			want:       PredecessorsResult{Page: 1, Direct: []int{3}, Transitive: []int{}, Cycle: []int{1, 2, 3}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "cycle within the update",
+			server:     cyclicServer(),
+			target:     "/predecessors?page=2&update=2,3,1",
+			want:       PredecessorsResult{Page: 2, Update: []int{2, 3, 1}, Direct: []int{1}, Transitive: []int{}, Cycle: []int{1, 2, 3}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "cycle left out of the update",
+			server:     cyclicServer(),
+			target:     "/predecessors?page=2&update=2,1",
+			want:       PredecessorsResult{Page: 2, Update: []int{2, 1}, Direct: []int{1}, Transitive: []int{1}},
+			wantStatus: http.StatusOK,
+		},
+		{name: "page not in the update", server: cyclicServer(), target: "/predecessors?page=2&update=1,3", wantStatus: http.StatusBadRequest},
+		{name: "missing page", server: cyclicServer(), target: "/predecessors", wantStatus: http.StatusBadRequest},
+		{name: "invalid page", server: cyclicServer(), target: "/predecessors?page=one", wantStatus: http.StatusBadRequest},
+		{name: "invalid update", server: cyclicServer(), target: "/predecessors?page=1&update=1,1", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var response struct {
+				PredecessorsResult
+				Error string `json:"error"`
+			}
+			status := request(t, test.server.Handler(), http.MethodGet, test.target, "", &response)
+			if status != test.wantStatus {
+				t.Fatalf("status = %d, want %d (error %q)", status, test.wantStatus, response.Error)
+			}
+			if status != http.StatusOK {
+				if response.Error == "" {
+					t.Error("error response has no error message")
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(response.PredecessorsResult, test.want) {
+				t.Errorf("predecessors = %+v, want %+v", response.PredecessorsResult, test.want)
+			}
+		})
+	}
+
+	t.Run("wrong method", func(t *testing.T) {
+		var response map[string]string
+		handler := cyclicServer().Handler()
+		if status := request(t, handler, http.MethodPost, "/predecessors?page=1", "", &response); status != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want %d", status, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// The full puzzle rules form a single cycle through every page, so a page has no meaningful predecessors
+// without an update.
+func TestPuzzleRulesCycle(t *testing.T) {
+	server := newTestServer(t, "input/puzzle_input.txt")
+
+	result, err := server.Predecessors(15, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Cycle) != 49 || len(result.Transitive) != 0 {
+		t.Errorf("page 15 has a cycle of %d pages and %d pages before it, want 49 and 0", len(result.Cycle), len(result.Transitive))
+	}
+
+	// Within an update, only the rules between its pages apply, and they are never cyclic in the puzzle
+	pages := []int{15, 11, 12}
+	result, err = server.Predecessors(15, pages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Cycle != nil {
+		t.Errorf("page 15 is on a cycle %v within update %v", result.Cycle, pages)
+	}
+	for _, page := range result.Transitive {
+		if !server.index.Within(pages).Precedes(page, 15) {
+			t.Errorf("page %d is listed before page 15 but the rules of the update do not require it", page)
+		}
+	}
+}
+
+func TestREPL(t *testing.T) {
+	server := newTestServer(t, "input/test_input.txt")
+
+	input := "check 75,97,47,61,53\nfix 61,13,29\nbefore 61 in 75,47,61\nbefore 61 in 75,47\nnope\nquit\n"
+	var output strings.Builder
+	if err := server.RunREPL(strings.NewReader(input), &output); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"breaks 97|75: 97 is at position 2, after 75 at position 1",
+		"> 61,29,13\nmoved 13 from position 2 to 3\n",
+		"direct: 47,75\ntransitive: 47,75\n",
+		"error: page 61 is not in the update",
+		`error: unknown command "nope", try help`,
+	} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, output.String())
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Step is the position of the guard and the direction it faces after one step of its patrol.
+type Step struct {
+	Row       int
+	Col       int
+	Direction string
+}
+
+// ANSI escape codes used by the terminal animation.
+const (
+	ansiClear     = "\x1b[2J"
+	ansiHome      = "\x1b[H"
+	ansiClearLine = "\x1b[K"
+	ansiReset     = "\x1b[0m"
+	ansiWall      = "\x1b[1m"
+	ansiVisited   = "\x1b[36m"
+	ansiGuard     = "\x1b[1;33m"
+)
+
+// patrolSteps returns every step of the guard's patrol, from its start until it leaves the map. It follows the
+// guard with moveGuard, one straight run at a time, and fills in the cells of each run. A turn is a step of its
+// own, where the guard stays in place and faces the new direction. The matrix is left untouched.
+func patrolSteps(matrix [][]string, i, j int, guard string) []Step {
+	matrix = copyMatrix(matrix)
+	steps := []Step{{Row: i, Col: j, Direction: guard}}
+
+	for {
+		nextI, nextJ, nextGuard := moveGuard(matrix, i, j, guard)
+		left := nextI == -1 && nextJ == -1
+
+		// When the guard leaves the map, its run ends on the edge
+		rowStep, colStep := guardDelta(guard)
+		if left {
+			nextI, nextJ = i, j
+			for inMap(matrix, nextI+rowStep, nextJ+colStep) {
+				nextI, nextJ = nextI+rowStep, nextJ+colStep
+			}
+		}
+
+		for i != nextI || j != nextJ {
+			i, j = i+rowStep, j+colStep
+			steps = append(steps, Step{Row: i, Col: j, Direction: guard})
+		}
+		if left {
+			return steps
+		}
+
+		guard = nextGuard
+		steps = append(steps, Step{Row: i, Col: j, Direction: guard})
+	}
+}
+
+// guardDelta returns the change of row and column of a step forward for the guard.
+func guardDelta(guard string) (int, int) {
+	switch guard {
+	case "^":
+		return -1, 0
+	case "v":
+		return 1, 0
+	case "<":
+		return 0, -1
+	case ">":
+		return 0, 1
+	}
+	return 0, 0
+}
+
+// inMap returns if the position is in the map.
+func inMap(matrix [][]string, i, j int) bool {
+	return i >= 0 && i < len(matrix) && j >= 0 && j < len(matrix[i])
+}
+
+// frameSteps returns the index of the step shown in each frame, moving stepsPerFrame steps at a time and always
+// ending on the last step.
+func frameSteps(steps int, stepsPerFrame int) []int {
+	var frames []int
+	for index := 0; index < steps-1; index += stepsPerFrame {
+		frames = append(frames, index)
+	}
+	return append(frames, steps-1)
+}
+
+// Animate draws the guard's patrol in the terminal, redrawing the map fps times per second with the cells visited
+// so far and the guard facing its direction. Each frame moves the guard stepsPerFrame steps forward.
+func Animate(w io.Writer, matrix [][]string, steps []Step, fps int, stepsPerFrame int) error {
+	grid := copyMatrix(matrix)
+	grid[steps[0].Row][steps[0].Col] = "."
+
+	out := bufio.NewWriter(w)
+	ticker := time.NewTicker(time.Second / time.Duration(fps))
+	defer ticker.Stop()
+
+	fmt.Fprint(out, ansiClear)
+	visited := 0
+	for frame, index := range frameSteps(len(steps), stepsPerFrame) {
+		if frame > 0 {
+			<-ticker.C
+		}
+
+		// Mark the cells the guard left since the last frame
+		for ; visited < index; visited++ {
+			grid[steps[visited].Row][steps[visited].Col] = "X"
+		}
+
+		drawFrame(out, grid, steps[index])
+		fmt.Fprintf(out, "step %d of %d, row %d, col %d%s\n", index+1, len(steps), steps[index].Row, steps[index].Col, ansiClearLine)
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drawFrame writes the map over the previous frame, with the guard at its step.
+func drawFrame(out *bufio.Writer, grid [][]string, guard Step) {
+	fmt.Fprint(out, ansiHome)
+	for row, cells := range grid {
+		for col, cell := range cells {
+			switch {
+			case row == guard.Row && col == guard.Col:
+				fmt.Fprint(out, ansiGuard, guard.Direction, ansiReset)
+			case cell == "#":
+				fmt.Fprint(out, ansiWall, cell, ansiReset)
+			case cell == "X":
+				fmt.Fprint(out, ansiVisited, cell, ansiReset)
+			default:
+				fmt.Fprint(out, cell)
+			}
+		}
+		fmt.Fprintln(out)
+	}
+}
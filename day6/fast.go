@@ -0,0 +1,224 @@
+package main
+
+import (
+	"sync"
+)
+
+// guards lists the guard symbols clockwise, so turning right is the next one.
+var guards = []string{"^", ">", "v", "<"}
+
+// Lab is the map of the lab prepared for fast patrols. For every cell, it holds the nearest wall in each direction,
+// so each straight run of the guard takes constant time instead of a step per cell.
+type Lab struct {
+	rows int
+	cols int
+	// wallUp and wallDown hold the row of the nearest wall above and below each cell, or -1 and rows past the edge.
+	// wallLeft and wallRight hold the column of the nearest wall left and right of each cell, or -1 and cols.
+	wallUp    []int
+	wallDown  []int
+	wallLeft  []int
+	wallRight []int
+}
+
+// NewLab builds the jump tables of the map. Rows must all have the same length.
+func NewLab(matrix [][]string) *Lab {
+	rows, cols := len(matrix), len(matrix[0])
+	l := &Lab{
+		rows:      rows,
+		cols:      cols,
+		wallUp:    make([]int, rows*cols),
+		wallDown:  make([]int, rows*cols),
+		wallLeft:  make([]int, rows*cols),
+		wallRight: make([]int, rows*cols),
+	}
+
+	for col := 0; col < cols; col++ {
+		wall := -1
+		for row := 0; row < rows; row++ {
+			l.wallUp[row*cols+col] = wall
+			if matrix[row][col] == "#" {
+				wall = row
+			}
+		}
+
+		wall = rows
+		for row := rows - 1; row >= 0; row-- {
+			l.wallDown[row*cols+col] = wall
+			if matrix[row][col] == "#" {
+				wall = row
+			}
+		}
+	}
+
+	for row := 0; row < rows; row++ {
+		wall := -1
+		for col := 0; col < cols; col++ {
+			l.wallLeft[row*cols+col] = wall
+			if matrix[row][col] == "#" {
+				wall = col
+			}
+		}
+
+		wall = cols
+		for col := cols - 1; col >= 0; col-- {
+			l.wallRight[row*cols+col] = wall
+			if matrix[row][col] == "#" {
+				wall = col
+			}
+		}
+	}
+
+	return l
+}
+
+// run moves the guard like moveGuard, one straight run at a time, with an extra wall at the obstruction cell,
+// and returns the first repeated turn if the guard loops. seen is scratch space of 4 entries per cell, and every
+// run must use a stamp different from the runs before it, so seen never needs clearing. If turns is not nil, the
+// turns before the repeated one are appended to it.
+func (l *Lab) run(row, col, direction int, obstruction Cell, seen []uint32, stamp uint32, turns *[]Turn) (Turn, bool) {
+	for {
+		cell := row*l.cols + col
+		switch guards[direction] {
+		case "^":
+			wall := l.wallUp[cell]
+			if obstruction.Col == col && obstruction.Row < row && obstruction.Row > wall {
+				wall = obstruction.Row
+			}
+			if wall < 0 {
+				return Turn{}, false
+			}
+			row = wall + 1
+		case "v":
+			wall := l.wallDown[cell]
+			if obstruction.Col == col && obstruction.Row > row && obstruction.Row < wall {
+				wall = obstruction.Row
+			}
+			if wall >= l.rows {
+				return Turn{}, false
+			}
+			row = wall - 1
+		case "<":
+			wall := l.wallLeft[cell]
+			if obstruction.Row == row && obstruction.Col < col && obstruction.Col > wall {
+				wall = obstruction.Col
+			}
+			if wall < 0 {
+				return Turn{}, false
+			}
+			col = wall + 1
+		case ">":
+			wall := l.wallRight[cell]
+			if obstruction.Row == row && obstruction.Col > col && obstruction.Col < wall {
+				wall = obstruction.Col
+			}
+			if wall >= l.cols {
+				return Turn{}, false
+			}
+			col = wall - 1
+		}
+
+		direction = (direction + 1) % len(guards)
+		state := (row*l.cols+col)*len(guards) + direction
+		if seen[state] == stamp {
+			return Turn{Row: row, Col: col, Direction: guards[direction]}, true
+		}
+		seen[state] = stamp
+
+		if turns != nil {
+			*turns = append(*turns, Turn{Row: row, Col: col, Direction: guards[direction]})
+		}
+	}
+}
+
+// loopFrom returns the turns of the loop starting from the given turn, with the extra wall at the obstruction cell.
+func (l *Lab) loopFrom(start Turn, obstruction Cell) []Turn {
+	seen := make([]uint32, l.rows*l.cols*len(guards))
+	direction := guardIndex(start.Direction)
+	seen[(start.Row*l.cols+start.Col)*len(guards)+direction] = 1
+
+	// The run stops when it comes back to the start, the only turn seen before it
+	loop := []Turn{start}
+	l.run(start.Row, start.Col, direction, obstruction, seen, 1, &loop)
+	return loop
+}
+
+// guardIndex returns the index of the guard symbol in guards.
+func guardIndex(guard string) int {
+	for i, symbol := range guards {
+		if symbol == guard {
+			return i
+		}
+	}
+	return -1
+}
+
+// Cell is a position in the map.
+type Cell struct {
+	Row int `json:"row"`
+	Col int `json:"col"`
+}
+
+// Obstruction is a cell where an extra wall makes the guard loop, with the turns of the loop.
+type Obstruction struct {
+	Cell
+	Loop []Turn `json:"loop"`
+}
+
+// FindObstructions returns every cell where an extra wall makes the guard loop, in reading order. Only the cells of
+// the guard's patrol are tried, since a wall anywhere else is never reached. The candidates are shared among a pool
+// of workers, each with its own scratch space, and the map is never copied. With loops set, the turns of each loop
+// are returned too.
+func (l *Lab) FindObstructions(patrol []Cell, startRow, startCol int, startGuard string, workers int, loops bool) []Obstruction {
+	found := make([]bool, len(patrol))
+	repeated := make([]Turn, len(patrol))
+
+	candidates := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < max(workers, 1); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			seen := make([]uint32, l.rows*l.cols*len(guards))
+			stamp := uint32(0)
+			for index := range candidates {
+				stamp++
+				repeated[index], found[index] = l.run(startRow, startCol, guardIndex(startGuard), patrol[index], seen, stamp, nil)
+			}
+		}()
+	}
+
+	for index := range patrol {
+		candidates <- index
+	}
+	close(candidates)
+	wg.Wait()
+
+	var obstructions []Obstruction
+	for index, cell := range patrol {
+		if !found[index] {
+			continue
+		}
+
+		obstruction := Obstruction{Cell: cell}
+		if loops {
+			obstruction.Loop = l.loopFrom(repeated[index], cell)
+		}
+		obstructions = append(obstructions, obstruction)
+	}
+	return obstructions
+}
+
+// patrolCells returns the cells the guard visited in the part 1 matrix, in reading order, leaving out the cell the
+// guard starts on, where no wall can be placed.
+func patrolCells(matrix [][]string, startRow, startCol int) []Cell {
+	var cells []Cell
+	for row, letters := range matrix {
+		for col, cell := range letters {
+			if cell == "X" && (row != startRow || col != startCol) {
+				cells = append(cells, Cell{Row: row, Col: col})
+			}
+		}
+	}
+	return cells
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+)
+
+// cellPixels is the size in pixels of a cell of the map in images.
+const cellPixels = 6
+
+// Indices of the colours in imagePalette.
+const (
+	colorFloor uint8 = iota
+	colorWall
+	colorVisited
+	colorGuard
+	colorGuardFront
+	colorObstruction
+)
+
+// imagePalette holds the colours of the images, shared by the GIF frames and the PNG.
+var imagePalette = color.Palette{
+	colorFloor:       color.RGBA{R: 0xf4, G: 0xf1, B: 0xe8, A: 0xff},
+	colorWall:        color.RGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff},
+	colorVisited:     color.RGBA{R: 0x9f, G: 0xd3, B: 0xe0, A: 0xff},
+	colorGuard:       color.RGBA{R: 0xf5, G: 0xb0, B: 0x1e, A: 0xff},
+	colorGuardFront:  color.RGBA{R: 0x8a, G: 0x4b, B: 0x00, A: 0xff},
+	colorObstruction: color.RGBA{R: 0xe6, G: 0x19, B: 0x4b, A: 0xff},
+}
+
+// WriteGIF writes the guard's patrol as an animated GIF playing at fps frames per second, each frame moving the
+// guard stepsPerFrame steps forward. GIF delays are in hundredths of a second, so fps is capped at 50. After the
+// full map in the first frame, each frame only holds the cells that changed since the one before.
+func WriteGIF(w io.Writer, matrix [][]string, steps []Step, fps int, stepsPerFrame int) error {
+	grid := copyMatrix(matrix)
+	grid[steps[0].Row][steps[0].Col] = "."
+
+	delay := max(100/fps, 2)
+	animation := &gif.GIF{
+		Config: image.Config{
+			ColorModel: imagePalette,
+			Width:      len(grid[0]) * cellPixels,
+			Height:     len(grid) * cellPixels,
+		},
+	}
+
+	visited := 0
+	for frame, index := range frameSteps(len(steps), stepsPerFrame) {
+		// The frame covers the cells the guard left since the last frame and the cell it is on now
+		bounds := image.Rect(0, 0, len(grid[0]), len(grid))
+		if frame > 0 {
+			bounds = image.Rect(steps[index].Col, steps[index].Row, steps[index].Col+1, steps[index].Row+1)
+			for _, step := range steps[visited:index] {
+				bounds = bounds.Union(image.Rect(step.Col, step.Row, step.Col+1, step.Row+1))
+			}
+		}
+
+		for ; visited < index; visited++ {
+			grid[steps[visited].Row][steps[visited].Col] = "X"
+		}
+
+		img := image.NewPaletted(image.Rect(bounds.Min.X*cellPixels, bounds.Min.Y*cellPixels, bounds.Max.X*cellPixels, bounds.Max.Y*cellPixels), imagePalette)
+		for row := bounds.Min.Y; row < bounds.Max.Y; row++ {
+			for col := bounds.Min.X; col < bounds.Max.X; col++ {
+				paintCell(img, row, col, cellColor(grid[row][col]))
+			}
+		}
+		paintGuard(img, steps[index])
+
+		animation.Image = append(animation.Image, img)
+		animation.Delay = append(animation.Delay, delay)
+		animation.Disposal = append(animation.Disposal, gif.DisposalNone)
+	}
+
+	return gif.EncodeAll(w, animation)
+}
+
+// WriteObstructionsPNG writes a PNG of the map after part 1, with the cells of the guard's patrol, the guard at
+// its start and every obstruction that makes the guard loop.
+func WriteObstructionsPNG(w io.Writer, part1Matrix [][]string, start Step, obstructions []Obstruction) error {
+	img := image.NewPaletted(image.Rect(0, 0, len(part1Matrix[0])*cellPixels, len(part1Matrix)*cellPixels), imagePalette)
+	for row, cells := range part1Matrix {
+		for col, cell := range cells {
+			paintCell(img, row, col, cellColor(cell))
+		}
+	}
+
+	for _, obstruction := range obstructions {
+		paintCell(img, obstruction.Row, obstruction.Col, colorObstruction)
+	}
+	paintGuard(img, start)
+
+	return png.Encode(w, img)
+}
+
+// cellColor returns the colour of a cell of the map.
+func cellColor(cell string) uint8 {
+	switch cell {
+	case "#":
+		return colorWall
+	case "X":
+		return colorVisited
+	}
+	return colorFloor
+}
+
+// paintCell fills a cell of the map with a colour.
+func paintCell(img *image.Paletted, row, col int, index uint8) {
+	for y := row * cellPixels; y < (row+1)*cellPixels; y++ {
+		for x := col * cellPixels; x < (col+1)*cellPixels; x++ {
+			img.SetColorIndex(x, y, index)
+		}
+	}
+}
+
+// paintGuard fills the guard's cell, with a darker strip on the side it faces.
+func paintGuard(img *image.Paletted, guard Step) {
+	paintCell(img, guard.Row, guard.Col, colorGuard)
+
+	top, left := guard.Row*cellPixels, guard.Col*cellPixels
+	strip := cellPixels / 3
+	for y := top; y < top+cellPixels; y++ {
+		for x := left; x < left+cellPixels; x++ {
+			front := false
+			switch guard.Direction {
+			case "^":
+				front = y < top+strip
+			case "v":
+				front = y >= top+cellPixels-strip
+			case "<":
+				front = x < left+strip
+			case ">":
+				front = x >= left+cellPixels-strip
+			}
+			if front {
+				img.SetColorIndex(x, y, colorGuardFront)
+			}
+		}
+	}
+}
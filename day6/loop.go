@@ -0,0 +1,32 @@
+package main
+
+// Turn is the state of the guard after a straight run: the position where it turned and the direction it faces.
+type Turn struct {
+	Row       int    `json:"row"`
+	Col       int    `json:"col"`
+	Direction string `json:"direction"`
+}
+
+// findLoop moves the guard from the given position until it leaves the map or repeats a turn. The guard moves
+// the same way every time it is in the same position facing the same direction, so a repeated turn means it is
+// stuck in a loop. If it is, findLoop returns true with the turns of the loop, starting from the repeated one.
+// Like moveGuard, it marks the cells the guard visits in the matrix.
+func findLoop(matrix [][]string, i, j int, guard string) ([]Turn, bool) {
+	var turns []Turn
+	seen := make(map[Turn]int)
+
+	for {
+		i, j, guard = moveGuard(matrix, i, j, guard)
+		if i == -1 && j == -1 {
+			return nil, false
+		}
+
+		turn := Turn{Row: i, Col: j, Direction: guard}
+		if index, ok := seen[turn]; ok {
+			return turns[index:], true
+		}
+
+		seen[turn] = len(turns)
+		turns = append(turns, turn)
+	}
+}
@@ -5,6 +5,13 @@ import (
 	"flag"
 	"log/slog"
 	"os"
+	"runtime"
+)
+
+// Part 2 solvers.
+const (
+	solverFast   = "fast"
+	solverSimple = "simple"
 )
 
 func main() {
@@ -12,8 +19,21 @@ func main() {
 
 	// Get input file name
 	inputFlag := flag.String("input", "input/test_input.txt", "A file containing puzzle inputs.")
+	loopsFlag := flag.Bool("loops", false, "Log every obstruction that makes the guard loop, with the turns of the loop.")
+	solverFlag := flag.String("solver", solverFast, "The part 2 solver: fast, or simple to copy the map and walk it for every cell.")
+	workersFlag := flag.Int("workers", runtime.NumCPU(), "The number of goroutines trying obstructions in the fast solver.")
+	animateFlag := flag.Bool("animate", false, "Animate the guard's patrol in the terminal.")
+	fpsFlag := flag.Int("fps", 10, "The frames per second of the animation and the GIF.")
+	stepsPerFrameFlag := flag.Int("steps-per-frame", 1, "The steps the guard takes between frames of the animation and the GIF.")
+	gifFlag := flag.String("gif", "", "A file to write the guard's patrol to as an animated GIF.")
+	obstructionsPNGFlag := flag.String("obstructions-png", "", "A file to write a PNG of the map to, marking every obstruction that makes the guard loop.")
 	flag.Parse()
 
+	// The animation redraws the terminal on stdout, so the logs go to stderr
+	if *animateFlag {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+
 	inputFileName := *inputFlag
 	logger = logger.With(
 		slog.String("inputFileName", inputFileName),
@@ -62,44 +82,94 @@ func main() {
 	// Part 1 Solution
 	logger.Info("result #1 is ready!", "space count", countSpaces(part1Matrix))
 
-	// Part 2
-	stuckCount := 0
-	maxSteps := len(matrix) * len(matrix[0])
+	// Visualize the patrol one step at a time
+	if *animateFlag || *gifFlag != "" {
+		if *fpsFlag <= 0 || *stepsPerFrameFlag <= 0 {
+			logger.Error("fps and steps per frame must be positive", "fps", *fpsFlag, "steps per frame", *stepsPerFrameFlag)
+			os.Exit(1)
+		}
 
-	for row := 0; row < len(matrix); row++ {
-		for col := 0; col < len(matrix[row]); col++ {
-			part2Matrix := copyMatrix(matrix)
+		steps := patrolSteps(matrix, startingI, startingJ, startingGuard)
 
-			// Turn the current cell into a wall if possible
-			if matrix[row][col] != "." {
-				continue
-			} else {
-				part2Matrix[row][col] = "#"
+		if *animateFlag {
+			if err := Animate(os.Stdout, matrix, steps, *fpsFlag, *stepsPerFrameFlag); err != nil {
+				logger.Error("failed to animate the patrol", "error", err)
+				os.Exit(1)
 			}
+		}
 
-			i, j, guard := startingI, startingJ, startingGuard
-			steps := 0
+		if *gifFlag != "" {
+			output, err := os.Create(*gifFlag)
+			if err != nil {
+				logger.Error("failed to create gif file", "error", err)
+				os.Exit(1)
+			}
+			defer output.Close()
 
-			for {
-				i, j, guard = moveGuard(part2Matrix, i, j, guard)
-				if i == -1 && j == -1 {
-					break
-				}
+			if err := WriteGIF(output, matrix, steps, *fpsFlag, *stepsPerFrameFlag); err != nil {
+				logger.Error("failed to write gif", "error", err)
+				os.Exit(1)
+			}
+			logger.Info("patrol gif written", "file", *gifFlag, "steps", len(steps))
+		}
+	}
 
-				steps++
+	// Part 2
+	var obstructions []Obstruction
+
+	switch *solverFlag {
+	case solverFast:
+		// Only try the cells on the guard's patrol, with jump tables instead of copies of the map
+		lab := NewLab(matrix)
+		obstructions = lab.FindObstructions(patrolCells(part1Matrix, startingI, startingJ), startingI, startingJ, startingGuard, *workersFlag, *loopsFlag)
+	case solverSimple:
+		for row := 0; row < len(matrix); row++ {
+			for col := 0; col < len(matrix[row]); col++ {
+				part2Matrix := copyMatrix(matrix)
+
+				// Turn the current cell into a wall if possible
+				if matrix[row][col] != "." {
+					continue
+				} else {
+					part2Matrix[row][col] = "#"
+				}
 
-				// If the guard has taken more steps than the maximum possible steps, it's stuck
-				if steps > maxSteps {
-					stuckCount++
-					break
+				// If the guard repeats a turn, it's stuck
+				loop, stuck := findLoop(part2Matrix, startingI, startingJ, startingGuard)
+				if stuck {
+					obstructions = append(obstructions, Obstruction{Cell: Cell{Row: row, Col: col}, Loop: loop})
 				}
 			}
 		}
+	default:
+		logger.Error("unknown solver", "solver", *solverFlag)
+		os.Exit(1)
+	}
+
+	stuckCount := len(obstructions)
+	if *loopsFlag {
+		for _, obstruction := range obstructions {
+			logger.Info("loop found", "obstruction row", obstruction.Row, "obstruction col", obstruction.Col, "turns", obstruction.Loop)
+		}
+	}
+
+	if *obstructionsPNGFlag != "" {
+		output, err := os.Create(*obstructionsPNGFlag)
+		if err != nil {
+			logger.Error("failed to create png file", "error", err)
+			os.Exit(1)
+		}
+		defer output.Close()
+
+		start := Step{Row: startingI, Col: startingJ, Direction: startingGuard}
+		if err := WriteObstructionsPNG(output, part1Matrix, start, obstructions); err != nil {
+			logger.Error("failed to write png", "error", err)
+			os.Exit(1)
+		}
 	}
 
 	// Part 2 Solution
 	logger.Info("result #2 is ready!", "stuck count", stuckCount)
-
 }
 
 func findGuard(matrix [][]string) (bool, int, int, string) {